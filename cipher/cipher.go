@@ -0,0 +1,108 @@
+// cipher 配置内容的加解密，nacosSource通过Decrypter解密cipher-前缀的DataId与ENC(...)中的值
+package cipher
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+type Decrypter interface {
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// Encrypter 同时实现Encrypter时，nacosSource在写入cipher-前缀的DataId时加密
+type Encrypter interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+}
+
+// AESGCM 使用本地密钥的AES-GCM，密文为base64(nonce + 密文 + tag)
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM key的长度为16、24或32字节
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+// LoadKeyFile 从文件读取hex或base64编码的密钥
+func LoadKeyFile(path string) (*AESGCM, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseKey(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", path, err)
+	}
+	return NewAESGCM(key)
+}
+
+// ParseKey 解析hex或base64编码的密钥
+func ParseKey(s string) ([]byte, error) {
+	if key, err := hex.DecodeString(s); err == nil && validKeySize(len(key)) {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && validKeySize(len(key)) {
+		return key, nil
+	}
+	return nil, errors.New("key must be 16, 24 or 32 bytes encoded in hex or base64")
+}
+
+func validKeySize(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// GenerateKey 生成32字节的随机密钥，返回hex编码
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+func (a *AESGCM) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := a.aead.Seal(nonce, nonce, plaintext, nil)
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(out, sealed)
+	return out, nil
+}
+
+func (a *AESGCM) Decrypt(ciphertext []byte) ([]byte, error) {
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(ciphertext)))
+	n, err := base64.StdEncoding.Decode(sealed, []byte(strings.TrimSpace(string(ciphertext))))
+	if err != nil {
+		return nil, fmt.Errorf("cipher: invalid ciphertext: %v", err)
+	}
+	sealed = sealed[:n]
+	size := a.aead.NonceSize()
+	if len(sealed) < size+a.aead.Overhead() {
+		return nil, errors.New("cipher: ciphertext too short")
+	}
+	plaintext, err := a.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return nil, errors.New("cipher: message authentication failed")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,60 @@
+package cipher
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAESGCM(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "cipher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := a.Encrypt([]byte("p@ss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := a.Decrypt(ciphertext)
+	if err != nil || string(plaintext) != "p@ss" {
+		t.Fatalf("unexpected plaintext %q, err: %v", plaintext, err)
+	}
+	// 篡改密文
+	sealed, _ := base64.StdEncoding.DecodeString(string(ciphertext))
+	sealed[len(sealed)-1] ^= 1
+	if _, err := a.Decrypt([]byte(base64.StdEncoding.EncodeToString(sealed))); err == nil {
+		t.Fatal("expected authentication error")
+	}
+	// 其他密钥
+	other, err := NewAESGCM(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Decrypt(ciphertext); err == nil {
+		t.Fatal("expected authentication error")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	if _, err := ParseKey(base64.StdEncoding.EncodeToString(make([]byte, 24))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseKey("00112233"); err == nil {
+		t.Fatal("expected error for short key")
+	}
+}
@@ -0,0 +1,389 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/asim/go-micro/v3/logger"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Binding 将配置源绑定到结构体，每次合法的推送生成新的结构体并原子替换，读取无需加锁
+type Binding struct {
+	src   source.Source
+	typ   reflect.Type
+	value atomic.Value
+
+	mu        sync.Mutex
+	callbacks []func(old, new interface{})
+
+	watcher source.Watcher
+	once    sync.Once
+	done    chan struct{}
+}
+
+// Bind v为结构体指针，仅用于确定类型，Load返回与v类型相同的指针
+// 字段按json标签解析，default标签指定缺省值，validate标签支持required、min、max与oneof
+func Bind(src source.Source, v interface{}) (*Binding, error) {
+	typ := reflect.TypeOf(v)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("bind target must be a pointer to struct")
+	}
+	b := &Binding{
+		src:  src,
+		typ:  typ.Elem(),
+		done: make(chan struct{}),
+	}
+	cs, err := src.Read()
+	if err != nil {
+		return nil, err
+	}
+	value, err := b.decode(cs)
+	if err != nil {
+		return nil, err
+	}
+	b.value.Store(value)
+	if b.watcher, err = src.Watch(); err != nil {
+		return nil, err
+	}
+	go b.run()
+	return b, nil
+}
+
+// Load 返回当前的结构体指针，调用方不能修改其内容
+func (b *Binding) Load() interface{} {
+	return b.value.Load()
+}
+
+// OnChange 注册替换后的回调，回调按注册顺序在同一个协程中执行
+func (b *Binding) OnChange(fn func(old, new interface{})) {
+	b.mu.Lock()
+	b.callbacks = append(b.callbacks, fn)
+	b.mu.Unlock()
+}
+
+func (b *Binding) run() {
+	defer close(b.done)
+	for {
+		cs, err := b.watcher.Next()
+		if err != nil {
+			return
+		}
+		// 不合法的配置不替换，继续使用之前的值
+		value, err := b.decode(cs)
+		if err != nil {
+			logger.Logf(logger.ErrorLevel, "nacos bind config %s rejected, err:%v", b.src, err)
+			continue
+		}
+		old := b.value.Load()
+		b.value.Store(value)
+		b.mu.Lock()
+		callbacks := b.callbacks
+		b.mu.Unlock()
+		for _, fn := range callbacks {
+			fn(old, value)
+		}
+	}
+}
+
+// Stop 停止监听，返回时不会再调用回调，不能在回调中调用
+func (b *Binding) Stop() error {
+	var err error
+	b.once.Do(func() {
+		err = b.watcher.Stop()
+		<-b.done
+	})
+	return err
+}
+
+func (b *Binding) decode(cs *source.ChangeSet) (interface{}, error) {
+	if len(cs.Data) == 0 {
+		return nil, errors.New("empty config")
+	}
+	data, err := decodeChangeSet(cs)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = convertDurations(b.typ, data); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	ptr := reflect.New(b.typ)
+	if err := applyDefaults(ptr.Elem()); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, ptr.Interface()); err != nil {
+		return nil, err
+	}
+	if err := validateStruct("", ptr.Elem()); err != nil {
+		return nil, err
+	}
+	return ptr.Interface(), nil
+}
+
+// convertDurations 将time.Duration字段对应的字符串(如"5s")转换为纳秒，使其能够被json.Unmarshal解析
+func convertDurations(t reflect.Type, v interface{}) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		s, ok := v.(string)
+		if !ok {
+			return v, nil
+		}
+		d := reflect.New(durationType).Elem()
+		if err := setValue(d, s); err != nil {
+			return nil, err
+		}
+		return d.Int(), nil
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v, nil
+		}
+		return convertFields(t, m)
+	case reflect.Slice, reflect.Array:
+		items, ok := v.([]interface{})
+		if !ok {
+			return v, nil
+		}
+		converted := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := convertDurations(t.Elem(), item)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = c
+		}
+		return converted, nil
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v, nil
+		}
+		converted := make(map[string]interface{}, len(m))
+		for key, e := range m {
+			c, err := convertDurations(t.Elem(), e)
+			if err != nil {
+				return nil, err
+			}
+			converted[key] = c
+		}
+		return converted, nil
+	}
+	return v, nil
+}
+
+// convertFields 与encoding/json一致，键名不区分大小写地匹配字段，匿名结构体的字段展开到上一级
+func convertFields(t reflect.Type, m map[string]interface{}) (map[string]interface{}, error) {
+	converted := make(map[string]interface{}, len(m))
+	for key, e := range m {
+		converted[key] = e
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" && f.Type.Kind() == reflect.Struct {
+			var err error
+			if converted, err = convertFields(f.Type, converted); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		name := fieldName(f)
+		for key, e := range converted {
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			c, err := convertDurations(f.Type, e)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			converted[key] = c
+		}
+	}
+	return converted, nil
+}
+
+// fieldName 与encoding/json一致，优先使用json标签中的名称
+func fieldName(f reflect.StructField) string {
+	if tag := f.Tag.Get("json"); tag != "" {
+		if name := strings.Split(tag, ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+func applyDefaults(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			if err := applyDefaults(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		def, ok := f.Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, def); err != nil {
+			return fmt.Errorf("invalid default of %s: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		// 切片的缺省值以","分隔
+		items := strings.Split(s, ",")
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported kind %s", v.Kind())
+	}
+	return nil
+}
+
+func validateStruct(path string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		p := joinPath(path, fieldName(f))
+		fv := v.Field(i)
+		if rules, ok := f.Tag.Lookup("validate"); ok {
+			for _, rule := range strings.Split(rules, ",") {
+				if err := validateRule(fv, strings.TrimSpace(rule)); err != nil {
+					return fmt.Errorf("%s: %v", p, err)
+				}
+			}
+		}
+		if fv.Kind() == reflect.Struct {
+			if err := validateStruct(p, fv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateRule(v reflect.Value, rule string) error {
+	name, arg := rule, ""
+	if i := strings.Index(rule, "="); i >= 0 {
+		name, arg = rule[:i], rule[i+1:]
+	}
+	switch name {
+	case "":
+		return nil
+	case "required":
+		if v.IsZero() {
+			return errors.New("is required")
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("invalid rule %s", rule)
+		}
+		n, ok := measure(v)
+		if !ok {
+			return fmt.Errorf("rule %s doesn't apply to %s", rule, v.Kind())
+		}
+		if name == "min" && n < limit {
+			return fmt.Errorf("%v is less than %s", n, arg)
+		}
+		if name == "max" && n > limit {
+			return fmt.Errorf("%v is greater than %s", n, arg)
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, candidate := range strings.Fields(arg) {
+			if s == candidate {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s is not one of [%s]", s, arg)
+	default:
+		return fmt.Errorf("unknown rule %s", rule)
+	}
+	return nil
+}
+
+// measure 数值取其值，字符串、切片与map取长度
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
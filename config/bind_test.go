@@ -0,0 +1,78 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"testing"
+	"time"
+)
+
+type bindConfig struct {
+	Redis struct {
+		Addr string `json:"addr" validate:"required"`
+		DB   int    `json:"db" default:"1" validate:"min=0,max=15"`
+	} `json:"redis"`
+	Level   string        `json:"level" default:"info" validate:"oneof=debug info warn"`
+	Timeout time.Duration `json:"timeout" default:"3s"`
+	Hosts   []string      `json:"hosts" default:"a,b"`
+}
+
+func TestBind(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "bind.yaml", "redis:\n  addr: a:6379\n")
+	b, err := Bind(newMockSource(client, "bind.yaml"), &bindConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+	c := b.Load().(*bindConfig)
+	if c.Redis.Addr != "a:6379" || c.Redis.DB != 1 || c.Level != "info" || c.Timeout != 3*time.Second || len(c.Hosts) != 2 {
+		t.Fatalf("unexpected config %+v", c)
+	}
+
+	changed := make(chan *bindConfig, 10)
+	b.OnChange(func(old, new interface{}) {
+		changed <- new.(*bindConfig)
+	})
+	// 不合法的配置被忽略
+	publish(t, client, "bind.yaml", "redis:\n  addr: a:6379\n  db: 16\n")
+	publish(t, client, "bind.yaml", "redis:\n  addr: b:6379\nlevel: debug\ntimeout: 5s\n")
+	select {
+	case c := <-changed:
+		if c.Redis.Addr != "b:6379" || c.Level != "debug" || c.Redis.DB != 1 || c.Timeout != 5*time.Second {
+			t.Fatalf("unexpected config %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for change")
+	}
+	if c := b.Load().(*bindConfig); c.Redis.Addr != "b:6379" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+}
+
+func TestBindValidate(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "bind.yaml", "level: error\n")
+	if _, err := Bind(newMockSource(client, "bind.yaml"), &bindConfig{}); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if _, err := Bind(newMockSource(client, "bind.yaml"), bindConfig{}); err == nil {
+		t.Fatal("expected error for non-pointer target")
+	}
+	publish(t, client, "bind.yaml", "redis:\n  addr: a:6379\ntimeout: 5 seconds\n")
+	if _, err := Bind(newMockSource(client, "bind.yaml"), &bindConfig{}); err == nil {
+		t.Fatal("expected invalid duration error")
+	}
+}
+
+func TestBindDuration(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "bind.json", `{"redis":{"addr":"a:6379"},"timeout":"1m30s"}`)
+	b, err := Bind(newMockSource(client, "bind.json"), &bindConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+	if c := b.Load().(*bindConfig); c.Timeout != 90*time.Second {
+		t.Fatalf("unexpected timeout %v", c.Timeout)
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 压缩后的内容以该前缀开头
+const compressedPrefix = "gzip+base64:"
+
+// 分片配置的主DataId的内容为该前缀加json格式的清单
+const manifestPrefix = "#nacos-chunked:"
+
+// 清单中分片数与拼接后大小的上限，拒绝异常的清单
+const (
+	maxChunkParts = 1024
+	maxConfigSize = 100 << 20
+)
+
+var errChunkChecksum = errors.New("nacos config chunks checksum mismatch")
+
+// manifest 分片清单，Checksum为拼接后内容的sha256
+type manifest struct {
+	Parts    int    `json:"parts"`
+	Size     int    `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+func partDataId(dataId string, i int) string {
+	return dataId + ".part-" + strconv.Itoa(i)
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func compress(content string) (string, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return compressedPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func decompress(content string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(content, compressedPrefix)))
+	if err != nil {
+		return "", fmt.Errorf("decode compressed config failed: %v", err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("decompress config failed: %v", err)
+	}
+	defer r.Close()
+	plain, err := ioutil.ReadAll(io.LimitReader(r, maxConfigSize+1))
+	if err != nil {
+		return "", fmt.Errorf("decompress config failed: %v", err)
+	}
+	if len(plain) > maxConfigSize {
+		return "", fmt.Errorf("decompressed config exceeds %d bytes", maxConfigSize)
+	}
+	return string(plain), nil
+}
+
+// split 按size拆分，不拆开多字节字符
+func split(content string, size int) []string {
+	parts := make([]string, 0, len(content)/size+1)
+	for len(content) > size {
+		cut := size
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			cut = size
+		}
+		parts = append(parts, content[:cut])
+		content = content[cut:]
+	}
+	return append(parts, content)
+}
+
+// expand 拼接分片并解压，校验通过后返回原始内容
+func (n *nacosSource) expand(content string) (string, error) {
+	if strings.HasPrefix(content, manifestPrefix) {
+		var m manifest
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(content, manifestPrefix)), &m); err != nil {
+			return "", fmt.Errorf("invalid chunk manifest: %v", err)
+		}
+		if m.Parts <= 0 || m.Parts > maxChunkParts || m.Size < 0 || m.Size > maxConfigSize {
+			return "", fmt.Errorf("invalid chunk manifest: %d parts, size %d", m.Parts, m.Size)
+		}
+		var b strings.Builder
+		b.Grow(m.Size)
+		for i := 1; i <= m.Parts; i++ {
+			param := n.param.ConfigParam
+			param.DataId = partDataId(n.param.DataId, i)
+			part, err := n.config.GetConfig(param)
+			if err != nil {
+				return "", fmt.Errorf("get chunk %s failed: %v", param.DataId, err)
+			}
+			b.WriteString(part)
+			if b.Len() > m.Size {
+				return "", errChunkChecksum
+			}
+		}
+		// 分片与清单不是同时写入，读到一半新一半旧的内容时校验失败
+		content = b.String()
+		if len(content) != m.Size || checksum(content) != m.Checksum {
+			return "", errChunkChecksum
+		}
+	}
+	if strings.HasPrefix(content, compressedPrefix) {
+		return decompress(content)
+	}
+	return content, nil
+}
+
+// publishParts 先写入各分片，返回作为主DataId内容的清单
+func (n *nacosSource) publishParts(content string, size int) (string, int, error) {
+	parts := split(content, size)
+	for i, part := range parts {
+		param := n.param.ConfigParam
+		param.DataId = partDataId(n.param.DataId, i+1)
+		param.Content = part
+		ok, err := n.config.PublishConfig(param)
+		if err != nil {
+			return "", 0, err
+		}
+		if !ok {
+			return "", 0, fmt.Errorf("nacos publish chunk %s failed", param.DataId)
+		}
+	}
+	data, err := json.Marshal(manifest{
+		Parts:    len(parts),
+		Size:     len(content),
+		Checksum: checksum(content),
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return manifestPrefix + string(data), len(parts), nil
+}
+
+// deleteParts 删除从from开始的多余分片，直到某个分片不存在
+func (n *nacosSource) deleteParts(from int) error {
+	for i := from; ; i++ {
+		param := n.param.ConfigParam
+		param.DataId = partDataId(n.param.DataId, i)
+		if _, err := n.config.GetConfig(param); err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if _, err := n.config.DeleteConfig(param); err != nil {
+			return err
+		}
+	}
+}
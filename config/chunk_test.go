@@ -0,0 +1,93 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkSplit(t *testing.T) {
+	parts := split("ab配置cd", 3)
+	if strings.Join(parts, "") != "ab配置cd" {
+		t.Fatalf("unexpected parts %q", parts)
+	}
+	for _, part := range parts {
+		if !utf8.ValidString(part) {
+			t.Fatalf("rune split in part %q", part)
+		}
+	}
+}
+
+func TestChunkWrite(t *testing.T) {
+	client := mock.NewConfigClient()
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, "key"+strings.Repeat("x", i%7)+string(rune('a'+i%26))+": value")
+	}
+	data := strings.Join(lines, "\n") + "\n"
+	sour := newMockSource(client, "routes.yaml", mockParam("routes.yaml", nacos.ChunkSize(100)))
+	if err := sour.Write(&source.ChangeSet{Data: []byte(data), Format: "yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	if content := getConfig(t, client, "routes.yaml"); !strings.HasPrefix(content, manifestPrefix) {
+		t.Fatalf("unexpected manifest %q", content)
+	}
+	cs, err := sour.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cs.Data) != data {
+		t.Fatalf("unexpected data %q", cs.Data)
+	}
+
+	// 分片被修改后校验失败
+	if _, err := client.PublishConfig(vo.ConfigParam{DataId: partDataId("routes.yaml", 2), Group: "DEFAULT_GROUP", Content: "tampered"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sour.Read(); err != errChunkChecksum {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// 压缩后不再需要分片，之前的分片被删除
+	compressed := newMockSource(client, "routes.yaml", mockParam("routes.yaml", nacos.Compress(true), nacos.ChunkSize(4096)))
+	if err := compressed.Write(&source.ChangeSet{Data: []byte(data), Format: "yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	if content := getConfig(t, client, "routes.yaml"); !strings.HasPrefix(content, compressedPrefix) {
+		t.Fatalf("unexpected content %q", content)
+	}
+	if _, err := client.GetConfig(vo.ConfigParam{DataId: partDataId("routes.yaml", 1), Group: "DEFAULT_GROUP"}); !isNotFound(err) {
+		t.Fatalf("stale chunk not deleted, err:%v", err)
+	}
+	if cs, err := compressed.Read(); err != nil || string(cs.Data) != data {
+		t.Fatalf("unexpected read %v, %v", cs, err)
+	}
+}
+
+func TestChunkHostileManifest(t *testing.T) {
+	client := mock.NewConfigClient()
+	sour := newMockSource(client, "hostile.yaml")
+	manifests := []string{
+		`{"parts":0,"size":1,"checksum":""}`,
+		`{"parts":-1,"size":1,"checksum":""}`,
+		`{"parts":100000000,"size":1,"checksum":""}`,
+		`{"parts":1,"size":-1,"checksum":""}`,
+		`{"parts":1,"size":1099511627776,"checksum":""}`,
+	}
+	for _, m := range manifests {
+		publish(t, client, "hostile.yaml", manifestPrefix+m)
+		if _, err := sour.Read(); err == nil || !strings.Contains(err.Error(), "invalid chunk manifest") {
+			t.Errorf("%s: expected invalid manifest error, got %v", m, err)
+		}
+	}
+	// 分片超过清单中的大小时不再继续读取
+	publish(t, client, partDataId("hostile.yaml", 1), "too long")
+	publish(t, client, "hostile.yaml", manifestPrefix+`{"parts":2,"size":3,"checksum":""}`)
+	if _, err := sour.Read(); err != errChunkChecksum {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -2,13 +2,21 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/cipher"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/metrics"
+	"github.com/DMwangnima/nacos-plugin/signature"
 	"github.com/asim/go-micro/v3/config/source"
 	"github.com/asim/go-micro/v3/logger"
 	"github.com/nacos-group/nacos-sdk-go/clients"
 	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
 	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/util"
 	"github.com/nacos-group/nacos-sdk-go/vo"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -18,21 +26,99 @@ type nacosSource struct {
 	config  config_client.IConfigClient
 	options source.Options
 	param   nacos.ConfigOptions
+	metrics metrics.Metrics
+	hooks   hook.Hooks
+	// 配置的校验
+	validators []nacos.ConfigValidator
+	// 签名校验，未开启时为nil
+	signature *nacos.SignatureOptions
+	// 配置的解密，未开启时为nil
+	decrypter cipher.Decrypter
+	// 占位符替换，未开启时为nil
+	interpolator *interpolator
+	// 本地快照，未开启时为nil
+	snapshot *snapshot
+	// 本地历史，未开启时为nil
+	history *history
+	// 固定版本与推送的交付互斥
+	pinMu sync.Mutex
+	mu    sync.Mutex
+	// 最近一次读取或收到推送的内容的MD5，用于CompareAndSwap
+	md5 string
+	// 最近一次Read是否从快照返回
+	stale bool
+	// 最近一次Read返回的checksum，新的watcher不再交付相同的内容
+	checksum string
+	// 最近一次读取或收到推送的内容是否经过占位符替换或ENC(...)解密
+	transformed bool
+	// 所有watcher共用一个nacos监听
+	listenMu   sync.Mutex
+	watchers   map[*nacosWatcher]struct{}
+	stopResync chan struct{}
 }
 
+// ErrConflict 开启CompareAndSwap时，服务端的配置在最近一次读取后已被修改
+var ErrConflict = errors.New("nacos config has been modified since last read")
+
+// ErrTransformed 最近一次读取的内容经过占位符替换或ENC(...)解密时Read返回的不是服务端的原始内容，
+// 读取后修改再写入会发布明文密钥与替换后的值，因此拒绝Write
+var ErrTransformed = errors.New("nacos config with interpolation or ENC(...) decryption can't be written")
+
 func NewSource(opts ...source.Option) source.Source {
 	n := &nacosSource{
 		client:  nacos.ClientOptions{*constant.NewClientConfig()},
 		server:  make([]nacos.ServerOptions, 0),
 		options: source.NewOptions(opts...),
 	}
+	// 指定多个命名空间时合并各命名空间中的配置
+	if _, ok := n.options.Context.Value(nacos.NamespacesKey{}).([]string); ok {
+		return NewMergedSource(opts...)
+	}
 	if err := configure(n, opts...); err != nil {
 		panic(err)
 	}
+	// 匹配灰度规则时灰度配置存在则替换基础配置
+	if variant := grayVariant(n); variant != "" {
+		return newGraySource(n, variant)
+	}
 	return n
 }
 
+// clone 返回使用相同client与配置、但读取param对应配置的nacosSource
+func (n *nacosSource) clone(param nacos.ConfigOptions) *nacosSource {
+	return &nacosSource{
+		client:       n.client,
+		server:       n.server,
+		config:       n.config,
+		options:      n.options,
+		param:        param,
+		metrics:      n.metrics,
+		hooks:        n.hooks,
+		validators:   n.validators,
+		interpolator: n.interpolator,
+		decrypter:    n.decrypter,
+		signature:    n.signature,
+		snapshot:     n.snapshot,
+		history:      n.history,
+	}
+}
+
 func configure(n *nacosSource, opts ...source.Option) error {
+	if err := configureClient(n, opts...); err != nil {
+		return err
+	}
+	if param, ok := n.options.Context.Value(nacos.ConfParamKey{}).([]nacos.ConfigOption); ok {
+		for _, confOpt := range param {
+			confOpt(&n.param)
+		}
+	} else {
+		return errors.New("missing confParam options")
+	}
+	return nil
+}
+
+// configureClient 初始化与具体配置项无关的部分，合并配置源的各项共用
+func configureClient(n *nacosSource, opts ...source.Option) error {
 	for _, opt := range opts {
 		opt(&n.options)
 	}
@@ -49,6 +135,65 @@ func configure(n *nacosSource, opts ...source.Option) error {
 		return errors.New("missing client options")
 	}
 
+	n.metrics = metrics.Noop()
+	if m, ok := n.options.Context.Value(nacos.MetricsKey{}).(metrics.Metrics); ok {
+		n.metrics = m
+	}
+	if hooks, ok := n.options.Context.Value(nacos.HookKey{}).(hook.Hooks); ok {
+		n.hooks = hooks
+	}
+	if validators, ok := n.options.Context.Value(nacos.ValidatorKey{}).([]nacos.ConfigValidator); ok {
+		n.validators = validators
+	}
+	if sigOpts, ok := n.options.Context.Value(nacos.SignatureKey{}).([]nacos.SignatureOption); ok {
+		n.signature = &nacos.SignatureOptions{}
+		for _, sigOpt := range sigOpts {
+			sigOpt(n.signature)
+		}
+		if n.signature.Verifier == nil {
+			return errors.New("missing signature verifier")
+		}
+	}
+	if d, ok := n.options.Context.Value(nacos.DecrypterKey{}).(cipher.Decrypter); ok {
+		n.decrypter = d
+	}
+	if interOpts, ok := n.options.Context.Value(nacos.InterpolationKey{}).([]nacos.InterpolationOption); ok {
+		interOptions := nacos.InterpolationOptions{
+			Resolvers: make(map[string]nacos.Resolver),
+		}
+		for _, interOpt := range interOpts {
+			interOpt(&interOptions)
+		}
+		n.interpolator = newInterpolator(interOptions.Resolvers)
+	}
+
+	// 初始化快照，默认目录为CacheDir下的config-snapshot目录
+	if snapOpts, ok := n.options.Context.Value(nacos.SnapshotKey{}).([]nacos.SnapshotOption); ok {
+		snapOptions := nacos.SnapshotOptions{
+			Dir: filepath.Join(n.client.CacheDir, "config-snapshot"),
+		}
+		for _, snapOpt := range snapOpts {
+			snapOpt(&snapOptions)
+		}
+		n.snapshot = newSnapshot(snapOptions.Dir, snapOptions.MaxAge)
+	}
+
+	// 初始化历史，默认目录为CacheDir下的config-history目录
+	if histOpts, ok := n.options.Context.Value(nacos.HistoryKey{}).([]nacos.HistoryOption); ok {
+		histOptions := nacos.HistoryOptions{
+			Dir: filepath.Join(n.client.CacheDir, "config-history"),
+		}
+		for _, histOpt := range histOpts {
+			histOpt(&histOptions)
+		}
+		n.history = newHistory(histOptions.Dir, histOptions.Size)
+	}
+
+	// 若直接指定了configClient，则无需server配置
+	if config, ok := n.options.Context.Value(nacos.ConfigClientKey{}).(config_client.IConfigClient); ok {
+		n.config = config
+	}
+
 	// 初始化server
 	var defIp = "127.0.0.1"
 	var defPort uint64 = 8848
@@ -63,7 +208,7 @@ func configure(n *nacosSource, opts ...source.Option) error {
 			}
 			n.server = append(n.server, srvOptions)
 		}
-	} else {
+	} else if n.config == nil {
 		return errors.New("missing server options")
 	}
 
@@ -72,52 +217,412 @@ func configure(n *nacosSource, opts ...source.Option) error {
 		serverConfigs = append(serverConfigs, s.ServerConfig)
 	}
 
-	if param, ok := n.options.Context.Value(nacos.ConfParamKey{}).([]nacos.ConfigOption); ok {
-		for _, confOpt := range param {
-			confOpt(&n.param)
-		}
-	} else {
-		return errors.New("missing confParam options")
+	if n.config != nil {
+		return nil
 	}
 
 	var err error
-	n.config, err = clients.NewConfigClient(
+	n.config, err = newConfigClient(n.client.ClientConfig, serverConfigs)
+	return err
+}
+
+func newConfigClient(client constant.ClientConfig, servers []constant.ServerConfig) (config_client.IConfigClient, error) {
+	return clients.NewConfigClient(
 		vo.NacosClientParam{
-			ClientConfig:  &n.client.ClientConfig,
-			ServerConfigs: serverConfigs,
+			ClientConfig:  &client,
+			ServerConfigs: servers,
 		},
 	)
-	return err
 }
 
-func (n *nacosSource) Read() (*source.ChangeSet, error) {
+func (n *nacosSource) Read() (cs *source.ChangeSet, err error) {
+	done := n.observe(hook.ConfigRead, "read")
+	defer func() { done(err) }()
 	if n.config == nil {
 		return nil, errors.New("nacos config hasn't been initialized")
 	}
+	// 固定版本时不读取nacos
+	n.pinMu.Lock()
+	defer n.pinMu.Unlock()
+	if pinned, err := n.loadPinned(); err != nil || pinned != nil {
+		if pinned != nil {
+			n.setChecksum(pinned.Checksum)
+		}
+		return pinned, err
+	}
+	var newCs *source.ChangeSet
 	content, err := n.config.GetConfig(n.param.ConfigParam)
-	if err != nil {
+	if err == nil {
+		n.setMd5(content)
+		newCs, content, err = n.process(n.param.DataId, content, false)
+	} else {
 		logger.Logf(logger.ErrorLevel, "nacos getconfig failed, err:%v", err)
+	}
+	if err != nil {
+		// 配置不存在时不使用快照
+		if n.snapshot != nil && !isNotFound(err) {
+			if cs, snapErr := n.loadSnapshot(); snapErr == nil {
+				logger.Logf(logger.WarnLevel, "nacos getconfig %s served from snapshot", n)
+				n.setStale(true)
+				// 从快照启动时，nacos恢复后的内容即使相同也需要交付，以清除stale标记
+				n.setChecksum("")
+				cs.Source = n.String() + StaleSuffix
+				return cs, nil
+			}
+		}
 		return nil, err
 	}
-	newCs := &source.ChangeSet{
+	n.setStale(false)
+	n.setChecksum(newCs.Checksum)
+	n.saveSnapshot(content)
+	n.recordHistory(newCs, content)
+	return newCs, nil
+}
+
+// process 将nacos中的原始内容转换为交给go-micro config的ChangeSet，失败时记录日志与指标
+// 返回的body为去掉签名后的原始内容，verified为true(如来自本地快照)时不再校验签名
+func (n *nacosSource) process(dataId, content string, verified bool) (cs *source.ChangeSet, body string, err error) {
+	body = content
+	if !verified {
+		if body, err = n.expand(content); err == nil {
+			body, err = n.verify(body)
+		}
+	}
+	if err == nil {
+		cs, err = n.transform(dataId, body)
+	}
+	if err == nil {
+		err = n.validate(cs)
+	}
+	if err != nil {
+		logger.Logf(logger.ErrorLevel, "nacos config %s rejected, err:%v", n, err)
+		n.metrics.Counter(metrics.ConfigRejected, metrics.Labels{"data_id": n.param.DataId, "group": n.param.Group}, 1)
+		return nil, "", err
+	}
+	return cs, body, nil
+}
+
+// verify 开启签名校验时，校验末尾签名行或伴随DataId中的签名，返回去掉签名行后的内容
+func (n *nacosSource) verify(content string) (string, error) {
+	if n.signature == nil {
+		return content, nil
+	}
+	body, sig, ok := signature.Split(content)
+	if !ok && n.signature.Suffix != "" {
+		detached, err := n.config.GetConfig(n.signatureParam())
+		if err != nil && !isNotFound(err) {
+			return "", err
+		}
+		sig, ok = detached, err == nil
+	}
+	if !ok {
+		return "", signature.ErrUnsigned
+	}
+	if err := signature.Verify(n.signature.Verifier, body, sig); err != nil {
+		return "", err
+	}
+	return body, nil
+}
+
+// signatureParam 签名所在的伴随DataId
+func (n *nacosSource) signatureParam() vo.ConfigParam {
+	return vo.ConfigParam{
+		DataId: n.param.DataId + n.signature.Suffix,
+		Group:  n.param.Group,
+	}
+}
+
+func (n *nacosSource) transform(dataId, content string) (*source.ChangeSet, error) {
+	if content == "" {
+		return n.newChangeSet(dataId, content), nil
+	}
+	var err error
+	if n.decrypter != nil && isCipher(dataId) {
+		if content, err = decrypt(n.decrypter, content); err != nil {
+			return nil, err
+		}
+	}
+	format := resolveFormat(n.param.Format, dataId, content)
+	raw := content
+	if n.interpolator != nil {
+		if content, err = n.interpolator.interpolate(format, content); err != nil {
+			return nil, err
+		}
+	}
+	// 占位符替换的结果中也可能包含ENC(...)
+	if n.decrypter != nil {
+		if content, err = decryptValues(n.decrypter, format, content); err != nil {
+			return nil, err
+		}
+	}
+	n.setTransformed(content != raw)
+	cs := n.newChangeSet(dataId, content)
+	cs.Format = format
+	return cs, nil
+}
+
+func (n *nacosSource) newChangeSet(dataId, content string) *source.ChangeSet {
+	cs := &source.ChangeSet{
 		Data:      []byte(content),
-		Format:    "yml",
+		Format:    resolveFormat(n.param.Format, dataId, content),
 		Source:    n.String(),
 		Timestamp: time.Now(),
 	}
-	newCs.Checksum = newCs.Sum()
-	return newCs, nil
+	cs.Checksum = cs.Sum()
+	return cs
+}
+
+func (n *nacosSource) validate(cs *source.ChangeSet) error {
+	for _, validator := range n.validators {
+		if err := validator(cs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *nacosSource) snapshotKey() string {
+	return n.client.NamespaceId + "@@" + n.param.Group + "@@" + n.param.DataId
+}
+
+// saveSnapshot 快照保存去掉签名后的原始内容，读取时重新处理，空内容(配置被删除)不覆盖快照
+func (n *nacosSource) saveSnapshot(content string) {
+	if n.snapshot == nil || content == "" {
+		return
+	}
+	cs := &source.ChangeSet{
+		Data:      []byte(content),
+		Format:    resolveFormat(n.param.Format, n.param.DataId, content),
+		Timestamp: time.Now(),
+	}
+	cs.Checksum = cs.Sum()
+	if err := n.snapshot.save(n.snapshotKey(), cs); err != nil {
+		logger.Logf(logger.WarnLevel, "nacos save config snapshot of %s failed, err:%v", n, err)
+	}
+}
+
+func (n *nacosSource) loadSnapshot() (*source.ChangeSet, error) {
+	raw, err := n.snapshot.load(n.snapshotKey())
+	if err != nil {
+		return nil, err
+	}
+	// 快照中保存的是校验通过的内容
+	cs, _, err := n.process(n.param.DataId, string(raw.Data), true)
+	if err != nil {
+		return nil, err
+	}
+	cs.Timestamp = raw.Timestamp
+	return cs, nil
 }
 
-// 暂不支持写入配置，只允许通过nacos中心控制
-func (n *nacosSource) Write(cs *source.ChangeSet) error {
-	return errors.New("nacos config doesn't implement Write method")
+func (n *nacosSource) setStale(stale bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.stale = stale
+}
+
+func (n *nacosSource) isStale() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.stale
+}
+
+func (n *nacosSource) setChecksum(checksum string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.checksum = checksum
+}
+
+func (n *nacosSource) lastChecksum() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.checksum
+}
+
+func (n *nacosSource) setTransformed(transformed bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.transformed = transformed
+}
+
+func (n *nacosSource) isTransformed() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.transformed
+}
+
+// Write 通过PublishConfig发布配置，cs的格式与配置的格式不同时先转换格式
+// 最近一次读取的内容经过占位符替换或ENC(...)解密时返回ErrTransformed
+func (n *nacosSource) Write(cs *source.ChangeSet) (err error) {
+	done := n.observe(hook.ConfigWrite, "write")
+	defer func() { done(err) }()
+	if n.config == nil {
+		return errors.New("nacos config hasn't been initialized")
+	}
+	// cipher-开头的DataId写入时整体加密，占位符与ENC(...)无法还原
+	if n.isTransformed() {
+		return ErrTransformed
+	}
+	content, err := n.encode(cs)
+	if err != nil {
+		return err
+	}
+	if e, ok := n.decrypter.(cipher.Encrypter); ok && isCipher(n.param.DataId) {
+		if content, err = encrypt(e, content); err != nil {
+			return err
+		}
+	}
+	if n.param.Compress {
+		if content, err = compress(content); err != nil {
+			return err
+		}
+	}
+	if err = n.checkVersion(); err != nil {
+		return err
+	}
+	// 先写入分片再写入清单，清单的变化触发推送
+	var parts int
+	if size := n.param.ChunkSize; size > 0 && len(content) > size {
+		if content, parts, err = n.publishParts(content, size); err != nil {
+			return err
+		}
+	}
+	param := n.param.ConfigParam
+	param.Content = content
+	ok, err := n.config.PublishConfig(param)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("nacos publish config failed")
+	}
+	n.setMd5(content)
+	// 分片数减少时删除之前多出的分片
+	if err := n.deleteParts(parts + 1); err != nil {
+		logger.Logf(logger.WarnLevel, "nacos delete stale chunks of %s failed, err:%v", n, err)
+	}
+	return nil
+}
+
+// Delete 通过DeleteConfig删除配置
+func (n *nacosSource) Delete() (err error) {
+	done := n.observe(hook.ConfigDelete, "delete")
+	defer func() { done(err) }()
+	if n.config == nil {
+		return errors.New("nacos config hasn't been initialized")
+	}
+	if err = n.checkVersion(); err != nil {
+		return err
+	}
+	param := n.param.ConfigParam
+	ok, err := n.config.DeleteConfig(param)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("nacos delete config failed")
+	}
+	n.setMd5("")
+	if err := n.deleteParts(1); err != nil {
+		logger.Logf(logger.WarnLevel, "nacos delete chunks of %s failed, err:%v", n, err)
+	}
+	return nil
+}
+
+// Delete 删除NewSource创建的配置源对应的配置，灰度配置源删除当前使用的DataId
+func Delete(src source.Source) error {
+	n, ok := nacosSourceOf(src)
+	if !ok {
+		return errors.New("not a nacos config source")
+	}
+	return n.Delete()
+}
+
+// nacosSourceOf 返回src对应的单个nacosSource
+func nacosSourceOf(src source.Source) (*nacosSource, bool) {
+	switch s := src.(type) {
+	case *nacosSource:
+		return s, true
+	case *graySource:
+		return s.active(), true
+	}
+	return nil, false
+}
+
+func (n *nacosSource) encode(cs *source.ChangeSet) (string, error) {
+	target := resolveFormat(n.param.Format, n.param.DataId, string(cs.Data))
+	format := normalizeFormat(cs.Format)
+	if format == "" || format == target {
+		return string(cs.Data), nil
+	}
+	decoder, ok := encoderOf(format)
+	if !ok {
+		return "", fmt.Errorf("unsupported config format %s", format)
+	}
+	encoder, ok := encoderOf(target)
+	if !ok {
+		return "", fmt.Errorf("unsupported config format %s", target)
+	}
+	var data map[string]interface{}
+	if err := decoder.Decode(cs.Data, &data); err != nil {
+		return "", err
+	}
+	b, err := encoder.Encode(data)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// checkVersion 开启CompareAndSwap时检查服务端的MD5
+// sdk的PublishConfig不支持CAS，因此检查与写入之间仍可能被并发修改
+func (n *nacosSource) checkVersion() error {
+	if !n.param.CompareAndSwap {
+		return nil
+	}
+	var current string
+	content, err := n.config.GetConfig(n.param.ConfigParam)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if err == nil {
+		current = util.Md5(content)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if current != n.md5 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// setMd5 空内容表示配置不存在
+func (n *nacosSource) setMd5(content string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if content == "" {
+		n.md5 = ""
+		return
+	}
+	n.md5 = util.Md5(content)
+}
+
+// sdk在配置不存在时返回该错误
+func isNotFound(err error) bool {
+	return err.Error() == "config not found"
 }
 
 func (n *nacosSource) Watch() (source.Watcher, error) {
 	return newNacosWatcher(n)
 }
 
+// observe 配置的参数固定为data_id与group
+func (n *nacosSource) observe(name, op string) func(error) {
+	observer := hook.Observer{Hooks: n.hooks, Metrics: n.metrics, Requests: metrics.ConfigRequests, Latency: metrics.ConfigLatency}
+	_, done := observer.Observe(n.options.Context, name, op, map[string]string{"data_id": n.param.DataId, "group": n.param.Group})
+	return done
+}
+
 func (n *nacosSource) String() string {
 	return n.param.DataId + " " + n.param.Group
 }
@@ -3,8 +3,14 @@ package config
 import (
 	"fmt"
 	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/fault"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/metrics"
+	"github.com/DMwangnima/nacos-plugin/mock"
 	"github.com/asim/go-micro/v3/config"
 	"github.com/asim/go-micro/v3/config/source"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/vo"
 	"testing"
 )
 
@@ -86,3 +92,111 @@ func TestConfig(t *testing.T) {
 	}
 	fmt.Println(c)
 }
+
+// mockOptions 使用client的配置项，opts追加在后面
+func mockOptions(client config_client.IConfigClient, opts ...source.Option) []source.Option {
+	return append([]source.Option{
+		nacos.ConfClient(nacos.NamespaceId("mock")),
+		nacos.ConfigClient(client),
+	}, opts...)
+}
+
+// mockParam DEFAULT_GROUP中dataId的ConfParam
+func mockParam(dataId string, opts ...nacos.ConfigOption) source.Option {
+	return nacos.ConfParam(append([]nacos.ConfigOption{nacos.Group("DEFAULT_GROUP"), nacos.DataId(dataId)}, opts...)...)
+}
+
+// newMockSource 读取DEFAULT_GROUP中dataId的配置源，opts中的ConfParam会覆盖默认的ConfParam
+func newMockSource(client config_client.IConfigClient, dataId string, opts ...source.Option) source.Source {
+	return NewSource(mockOptions(client, append([]source.Option{mockParam(dataId)}, opts...)...)...)
+}
+
+func publish(t *testing.T, client config_client.IConfigClient, dataId, content string) {
+	t.Helper()
+	if _, err := client.PublishConfig(vo.ConfigParam{DataId: dataId, Group: "DEFAULT_GROUP", Content: content}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadFault(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "chaos-read", `{"a":1}`)
+	sour := newMockSource(fault.NewConfigClient(client, fault.ErrorRate(1), fault.MaxFaults(1)), "chaos-read")
+	if _, err := sour.Read(); err != fault.ErrInjected {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+	cs, err := sour.Read()
+	if err != nil || string(cs.Data) != `{"a":1}` {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+}
+
+func TestWatchDroppedPush(t *testing.T) {
+	client := mock.NewConfigClient()
+	sour := newMockSource(fault.NewConfigClient(client, fault.DropRate(1), fault.MaxFaults(1)), "chaos-watch")
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	// 第一次推送被丢弃
+	publish(t, client, "chaos-watch", `{"a":1}`)
+	publish(t, client, "chaos-watch", `{"a":2}`)
+	cs, err := w.Next()
+	if err != nil || string(cs.Data) != `{"a":2}` {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	client := mock.NewConfigClient()
+	m := metrics.NewMemory()
+	sour := newMockSource(client, "metrics",
+		nacos.ConfMetrics(m),
+	)
+	publish(t, client, "metrics", `{"a":0}`)
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	publish(t, client, "metrics", `{"a":1}`)
+	if _, err := w.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.CounterValue(metrics.ConfigRequests, metrics.Labels{"op": "read", "status": "ok"}); v != 1 {
+		t.Fatalf("expected 1 read, got %v", v)
+	}
+	if v := m.CounterValue(metrics.ConfigChanges, metrics.Labels{"data_id": "metrics", "group": "DEFAULT_GROUP"}); v != 1 {
+		t.Fatalf("expected 1 change, got %v", v)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	client := mock.NewConfigClient()
+	var ops []string
+	sour := newMockSource(client, "hooks",
+		nacos.ConfHooks(hook.Funcs{AfterFunc: func(op *hook.Operation) {
+			ops = append(ops, op.Name+" "+op.Params["data_id"])
+		}}),
+	)
+	publish(t, client, "hooks", `{"a":0}`)
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	publish(t, client, "hooks", `{"a":1}`)
+	if _, err := w.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 || ops[0] != hook.ConfigRead+" hooks" || ops[1] != hook.ConfigChange+" hooks" {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+}
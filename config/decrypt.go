@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"github.com/DMwangnima/nacos-plugin/cipher"
+	"regexp"
+	"strings"
+)
+
+// 与sdk的OpenKMS一致，cipher-前缀的DataId整个内容为密文
+const cipherPrefix = "cipher-"
+
+// 配置中以ENC(...)包裹的密文
+var encValue = regexp.MustCompile(`ENC\(([A-Za-z0-9+/=]+)\)`)
+
+func isCipher(dataId string) bool {
+	return strings.HasPrefix(dataId, cipherPrefix)
+}
+
+func decrypt(d cipher.Decrypter, content string) (string, error) {
+	plain, err := d.Decrypt([]byte(content))
+	if err != nil {
+		return "", fmt.Errorf("decrypt config failed: %v", err)
+	}
+	return string(plain), nil
+}
+
+func encrypt(e cipher.Encrypter, content string) (string, error) {
+	data, err := e.Encrypt([]byte(content))
+	if err != nil {
+		return "", fmt.Errorf("encrypt config failed: %v", err)
+	}
+	return string(data), nil
+}
+
+// decryptValues 解密所有ENC(...)，配置能够解析时在解析后的值中解密并重新编码，
+// 避免明文中的引号、换行等破坏配置格式，否则按文本替换
+func decryptValues(d cipher.Decrypter, format, content string) (string, error) {
+	if !encValue.MatchString(content) {
+		return content, nil
+	}
+	e, ok := encoderOf(format)
+	var doc map[string]interface{}
+	if !ok || e.Decode([]byte(content), &doc) != nil {
+		return decryptText(d, content)
+	}
+	v, err := mapStrings(doc, func(s string) (interface{}, error) {
+		return decryptText(d, s)
+	})
+	if err != nil {
+		return "", err
+	}
+	data, err := e.Encode(v)
+	if err != nil {
+		return "", fmt.Errorf("encode %s config failed: %v", format, err)
+	}
+	return string(data), nil
+}
+
+// decryptText 按文本替换s中的ENC(...)
+func decryptText(d cipher.Decrypter, s string) (string, error) {
+	var err error
+	result := encValue.ReplaceAllStringFunc(s, func(enc string) string {
+		if err != nil {
+			return enc
+		}
+		var plain []byte
+		plain, err = d.Decrypt([]byte(encValue.FindStringSubmatch(enc)[1]))
+		if err != nil {
+			err = fmt.Errorf("decrypt %s failed: %v", enc, err)
+			return enc
+		}
+		return string(plain)
+	})
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/cipher"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config/source"
+	"testing"
+)
+
+func testCipher(t *testing.T) *cipher.AESGCM {
+	t.Helper()
+	a, err := cipher.NewAESGCM([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestDecryptValues(t *testing.T) {
+	client := mock.NewConfigClient()
+	a := testCipher(t)
+	sour := newMockSource(client, "db.yaml", nacos.ConfDecrypter(a), nacos.ConfInterpolation())
+	password, err := a.Encrypt([]byte("p@ss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, client, "db.yaml", "user: root\npassword: ENC("+string(password)+")\nbackup: ${NACOS_TEST_MISSING:-ENC("+string(password)+")}\n")
+	cs, err := sour.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertYAML(t, string(cs.Data), "user: root\npassword: p@ss\nbackup: p@ss\n")
+	publish(t, client, "db.yaml", "password: ENC(aW52YWxpZA==)")
+	if _, err := sour.Read(); err == nil {
+		t.Fatal("expected decrypt error")
+	}
+}
+
+func TestDecryptValuesEscape(t *testing.T) {
+	a := testCipher(t)
+	// 明文中的引号、反斜杠、换行、冒号、#与yaml的指示符不破坏配置格式
+	plain := "*\"p\\a: s\n# s"
+	secret, err := a.Encrypt([]byte(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"yaml":       "password: ENC(" + string(secret) + ")\n",
+		"json":       `{"password": "ENC(` + string(secret) + `)"}`,
+		"properties": "password=ENC(" + string(secret) + ")\n",
+	}
+	for format, content := range cases {
+		result, err := decryptValues(a, format, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, _ := encoderOf(format)
+		var m map[string]interface{}
+		if err := e.Decode([]byte(result), &m); err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, result)
+		}
+		if m["password"] != plain {
+			t.Fatalf("%s: unexpected result %#v", format, m)
+		}
+	}
+}
+
+func TestDecryptCipherDataId(t *testing.T) {
+	client := mock.NewConfigClient()
+	sour := newMockSource(client, "cipher-db.yaml", nacos.ConfDecrypter(testCipher(t)))
+	// Write时加密整个内容
+	if err := sour.Write(&source.ChangeSet{Data: []byte(`{"password":"p@ss"}`), Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	if content := getConfig(t, client, "cipher-db.yaml"); content == "password: p@ss\n" {
+		t.Fatal("config stored in plain text")
+	}
+	cs, err := sour.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cs.Data) != "password: p@ss\n" || cs.Format != "yaml" {
+		t.Fatalf("unexpected changeset %q %s", cs.Data, cs.Format)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"strings"
 )
 
 var (
@@ -18,6 +19,16 @@ var (
 	serversEnvPrefix = "NACOS_SERVER_"
 )
 
+const (
+	profileEnv           = "NACOS_PROFILE"
+	groupEnv             = "NACOS_GROUP"
+	fileExtensionEnv     = "NACOS_FILE_EXTENSION"
+	sharedConfigsEnv     = "NACOS_SHARED_CONFIGS"
+	localConfigEnv       = "NACOS_LOCAL_CONFIG"
+	defaultGroup         = "DEFAULT_GROUP"
+	defaultFileExtension = "yaml"
+)
+
 type server struct {
 	ip   string
 	port int
@@ -83,9 +94,68 @@ func NewDefaultSource(serviceName string) source.Source {
 		nacos.NamespaceId(namespace),
 	)
 	srv := nacos.ConfServer(nodes...)
-	private := nacos.ConfParam(
-		nacos.Group("DEFAULT_GROUP"),
-		nacos.DataId(serviceName),
+	opts := append([]source.Option{cli, srv}, defaultEntries(serviceName)...)
+	sour := NewMergedSource(opts...)
+	// 开发时通过NACOS_LOCAL_CONFIG指定本地文件或目录，与APP_开头的环境变量一起覆盖nacos中的配置
+	if path := os.Getenv(localConfigEnv); path != "" {
+		return NewOverlaySource(sour, nacos.LocalPath(path))
+	}
+	return sour
+}
+
+// defaultEntries 与spring-cloud-alibaba一致，按以下顺序加载，后面的覆盖前面的:
+// 1. NACOS_SHARED_CONFIGS中以","分隔的共享配置，格式为dataId或dataId@group
+// 2. serviceName
+// 3. serviceName.ext
+// 4. NACOS_PROFILE中以","分隔的每个profile对应的serviceName-profile.ext
+// group默认为DEFAULT_GROUP，ext默认为yaml，各项均为可选项，但没有共享配置与profile时2、3中至少要有一项存在，
+// 避免服务在没有任何配置的情况下启动
+func defaultEntries(serviceName string) []source.Option {
+	group := os.Getenv(groupEnv)
+	if group == "" {
+		group = defaultGroup
+	}
+	ext := strings.TrimPrefix(os.Getenv(fileExtensionEnv), ".")
+	if ext == "" {
+		ext = defaultFileExtension
+	}
+	shares, profiles := splitEnv(sharedConfigsEnv), splitEnv(profileEnv)
+	entries := make([]source.Option, 0)
+	for _, shared := range shares {
+		dataId, sharedGroup := shared, group
+		if idx := strings.LastIndexByte(shared, '@'); idx >= 0 {
+			dataId, sharedGroup = shared[:idx], shared[idx+1:]
+		}
+		entries = append(entries, nacos.ConfEntry(
+			nacos.DataId(dataId),
+			nacos.Group(sharedGroup),
+			nacos.Optional(true),
+		))
+	}
+	var required string
+	if len(shares)+len(profiles) == 0 {
+		required = serviceName
+	}
+	entries = append(entries,
+		nacos.ConfEntry(nacos.DataId(serviceName), nacos.Group(group), nacos.Format(ext), nacos.Optional(true), nacos.RequiredGroup(required)),
+		nacos.ConfEntry(nacos.DataId(serviceName+"."+ext), nacos.Group(group), nacos.Optional(true), nacos.RequiredGroup(required)),
 	)
-	return NewSource(cli, srv, private)
+	for _, profile := range profiles {
+		entries = append(entries, nacos.ConfEntry(
+			nacos.DataId(serviceName+"-"+profile+"."+ext),
+			nacos.Group(group),
+			nacos.Optional(true),
+		))
+	}
+	return entries
+}
+
+func splitEnv(env string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(os.Getenv(env), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
 }
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"github.com/DMwangnima/nacos-plugin/mock"
 	"github.com/asim/go-micro/v3/config"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"os"
 	"testing"
 )
 
@@ -38,3 +41,52 @@ func TestNewDefaultSource(t *testing.T) {
 	}
 	t.Log(g)
 }
+
+func TestDefaultEntries(t *testing.T) {
+	os.Setenv(profileEnv, "dev, gray")
+	os.Setenv(sharedConfigsEnv, "common.yaml,redis.yaml@MIDDLEWARE")
+	defer os.Unsetenv(profileEnv)
+	defer os.Unsetenv(sharedConfigsEnv)
+
+	client := mock.NewConfigClient()
+	publish(t, client, "common.yaml", "a: common\nb: common\nc: common\nd: common\n")
+	if _, err := client.PublishConfig(vo.ConfigParam{DataId: "redis.yaml", Group: "MIDDLEWARE", Content: "redis: middleware\n"}); err != nil {
+		t.Fatal(err)
+	}
+	publish(t, client, "gateway", "b: gateway\n")
+	publish(t, client, "gateway.yaml", "c: gateway.yaml\n")
+	publish(t, client, "gateway-gray.yaml", "d: gateway-gray.yaml\n")
+	opts := mockOptions(client, defaultEntries("gateway")...)
+	conf, err := config.NewConfig(config.WithSource(NewMergedSource(opts...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conf.Close()
+	expected := map[string]string{
+		"a":     "common",
+		"b":     "gateway",
+		"c":     "gateway.yaml",
+		"d":     "gateway-gray.yaml",
+		"redis": "middleware",
+	}
+	for key, value := range expected {
+		if v := conf.Get(key).String(""); v != value {
+			t.Errorf("unexpected %s: %q", key, v)
+		}
+	}
+}
+
+func TestDefaultEntriesRequired(t *testing.T) {
+	client := mock.NewConfigClient()
+	opts := mockOptions(client, defaultEntries("gateway")...)
+	sour := NewMergedSource(opts...)
+	// 没有共享配置与profile时gateway与gateway.yaml至少要有一项存在
+	if _, err := sour.Read(); err == nil {
+		t.Fatal("expected error for missing gateway and gateway.yaml")
+	}
+	// 只有gateway的旧配置可以加载
+	publish(t, client, "gateway", "a: gateway\n")
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/asim/go-micro/v3/logger"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type ChangeType int
+
+const (
+	Added ChangeType = iota
+	Removed
+	Modified
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change 一个键的变化，Path以"."连接各级键，数组作为整体比较
+type Change struct {
+	Type ChangeType
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// String 敏感键的值被替换为RedactedValue，值为map或数组时递归替换其中的敏感键，用于打印日志
+func (c Change) String() string {
+	old, new := redact(c.Path, c.Old), redact(c.Path, c.New)
+	switch c.Type {
+	case Added:
+		return fmt.Sprintf("%s %s: %v", c.Type, c.Path, new)
+	case Removed:
+		return fmt.Sprintf("%s %s: %v", c.Type, c.Path, old)
+	}
+	return fmt.Sprintf("%s %s: %v -> %v", c.Type, c.Path, old, new)
+}
+
+const RedactedValue = "******"
+
+// SecretKeys 键名(不区分大小写)包含其中任一项时视为敏感键
+var SecretKeys = []string{"password", "passwd", "secret", "token", "credential", "privatekey", "private_key", "accesskey", "access_key", "apikey", "api_key"}
+
+// IsSecret 按Path的最后一级判断是否为敏感键
+func IsSecret(path string) bool {
+	key := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+	for _, s := range SecretKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact 返回替换了敏感键的值的副本
+func redact(path string, v interface{}) interface{} {
+	if IsSecret(path) {
+		return RedactedValue
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for key, e := range t {
+			m[key] = redact(joinPath(path, key), e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, e := range t {
+			s[i] = redact(path, e)
+		}
+		return s
+	}
+	return v
+}
+
+// Diff 比较两次解析后的配置，返回按Path排序的变化，nil或空配置视为没有任何键
+func Diff(old, new *source.ChangeSet) ([]Change, error) {
+	o, err := decodeMap(old)
+	if err != nil {
+		return nil, err
+	}
+	n, err := decodeMap(new)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	diffValue("", o, n, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// decodeMap 将cs解析为map，nil或空配置返回nil
+func decodeMap(cs *source.ChangeSet) (map[string]interface{}, error) {
+	if cs == nil || len(cs.Data) == 0 {
+		return nil, nil
+	}
+	data, err := decodeChangeSet(cs)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := data.(map[string]interface{})
+	return m, nil
+}
+
+func diffValue(path string, old, new map[string]interface{}, changes *[]Change) {
+	for key, o := range old {
+		p := joinPath(path, key)
+		n, ok := new[key]
+		if !ok {
+			*changes = append(*changes, Change{Type: Removed, Path: p, Old: o})
+			continue
+		}
+		om, oIsMap := o.(map[string]interface{})
+		nm, nIsMap := n.(map[string]interface{})
+		if oIsMap && nIsMap {
+			diffValue(p, om, nm, changes)
+			continue
+		}
+		if !reflect.DeepEqual(o, n) {
+			*changes = append(*changes, Change{Type: Modified, Path: p, Old: o, New: n})
+		}
+	}
+	for key, n := range new {
+		if _, ok := old[key]; !ok {
+			*changes = append(*changes, Change{Type: Added, Path: joinPath(path, key), New: n})
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Filter 返回Path等于某个前缀或位于其下的变化，未指定前缀时返回全部
+func Filter(changes []Change, prefixes ...string) []Change {
+	if len(prefixes) == 0 {
+		return changes
+	}
+	var filtered []Change
+	for _, c := range changes {
+		for _, prefix := range prefixes {
+			if c.Path == prefix || strings.HasPrefix(c.Path, prefix+".") {
+				filtered = append(filtered, c)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// Subscription 持续比较配置源相邻两次的内容
+type Subscription struct {
+	watcher source.Watcher
+	once    sync.Once
+	done    chan struct{}
+}
+
+// Subscribe 订阅src中位于prefixes下的键的变化，每次推送有相关变化时调用fn，变化会以脱敏后的形式打印日志
+func Subscribe(src source.Source, fn func([]Change), prefixes ...string) (*Subscription, error) {
+	if fn == nil {
+		return nil, errors.New("missing change callback")
+	}
+	last, err := src.Read()
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	watcher, err := src.Watch()
+	if err != nil {
+		return nil, err
+	}
+	s := &Subscription{
+		watcher: watcher,
+		done:    make(chan struct{}),
+	}
+	go s.run(src, last, fn, prefixes)
+	return s, nil
+}
+
+func (s *Subscription) run(src source.Source, last *source.ChangeSet, fn func([]Change), prefixes []string) {
+	defer close(s.done)
+	for {
+		cs, err := s.watcher.Next()
+		if err != nil {
+			return
+		}
+		changes, err := Diff(last, cs)
+		if err != nil {
+			logger.Logf(logger.ErrorLevel, "nacos diff config %s failed, err:%v", src, err)
+			continue
+		}
+		last = cs
+		changes = Filter(changes, prefixes...)
+		if len(changes) == 0 {
+			continue
+		}
+		for _, c := range changes {
+			logger.Logf(logger.InfoLevel, "nacos config %s %s", src, c)
+		}
+		fn(changes)
+	}
+}
+
+// Stop 停止订阅，返回时不会再调用fn，不能在fn中调用
+func (s *Subscription) Stop() error {
+	var err error
+	s.once.Do(func() {
+		err = s.watcher.Stop()
+		<-s.done
+	})
+	return err
+}
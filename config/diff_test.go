@@ -0,0 +1,86 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config/source"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	old := &source.ChangeSet{Format: "yaml", Data: []byte("redis:\n  addr: a:6379\n  db: 0\n  password: old\nlog: info\nhosts: [a, b]\n")}
+	new := &source.ChangeSet{Format: "json", Data: []byte(`{"redis":{"addr":"b:6379","db":0,"password":"new"},"hosts":["a","c"],"kafka":{"addr":"k:9092"}}`)}
+	changes, err := Diff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Change{
+		{Type: Modified, Path: "hosts", Old: []interface{}{"a", "b"}, New: []interface{}{"a", "c"}},
+		{Type: Added, Path: "kafka", New: map[string]interface{}{"addr": "k:9092"}},
+		{Type: Removed, Path: "log", Old: "info"},
+		{Type: Modified, Path: "redis.addr", Old: "a:6379", New: "b:6379"},
+		{Type: Modified, Path: "redis.password", Old: "old", New: "new"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+	if filtered := Filter(changes, "redis"); len(filtered) != 2 {
+		t.Fatalf("unexpected filtered changes %v", filtered)
+	}
+	if s := changes[4].String(); strings.Contains(s, "old") || strings.Contains(s, "new") {
+		t.Fatalf("secret not redacted: %s", s)
+	}
+	// 空配置视为没有任何键
+	changes, err = Diff(nil, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+}
+
+func TestDiffRedactNested(t *testing.T) {
+	old := &source.ChangeSet{Format: "yaml", Data: []byte("db:\n  password: s1\nusers:\n- name: a\n  token: s2\nauth: {apiKey: s3}\n")}
+	new := &source.ChangeSet{Format: "yaml", Data: []byte("users: none\nauth: plain\n")}
+	changes, err := Diff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 删除的子树、被替换为标量的map与对象数组中的敏感键都被替换
+	for _, c := range changes {
+		if s := c.String(); strings.Contains(s, "s1") || strings.Contains(s, "s2") || strings.Contains(s, "s3") {
+			t.Fatalf("secret not redacted: %s", s)
+		}
+	}
+	if s := changes[0].String(); !strings.Contains(s, "plain") {
+		t.Fatalf("unexpected change %s", s)
+	}
+}
+
+func TestDiffSubscribe(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "diff.yaml", "redis:\n  addr: a:6379\nlog: info\n")
+	sour := newMockSource(client, "diff.yaml")
+	changesCh := make(chan []Change, 10)
+	sub, err := Subscribe(sour, func(changes []Change) {
+		changesCh <- changes
+	}, "redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Stop()
+	// 前缀之外的变化不会通知
+	publish(t, client, "diff.yaml", "redis:\n  addr: a:6379\nlog: debug\n")
+	publish(t, client, "diff.yaml", "redis:\n  addr: b:6379\nlog: debug\n")
+	select {
+	case changes := <-changesCh:
+		if len(changes) != 1 || changes[0].Path != "redis.addr" || changes[0].New != "b:6379" {
+			t.Fatalf("unexpected changes %v", changes)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for changes")
+	}
+}
@@ -0,0 +1,416 @@
+// properties go-micro config的java .properties编解码
+// 以"."分隔的key解码为嵌套的map，"[n]"后缀解码为数组；
+// 布尔值与整数、浮点数会被转换为对应的类型，以便Scan到结构体中，其余值保持为字符串
+// 同一个key既有值又有子key(如logging.level=info与logging.level.root=warn)时子key优先，值被忽略并记录警告；
+// 数组下标不能超过MaxIndex
+package properties
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/asim/go-micro/v3/config/encoder"
+	"github.com/asim/go-micro/v3/logger"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type propertiesEncoder struct{}
+
+func (p propertiesEncoder) Encode(v interface{}) ([]byte, error) {
+	// 先经过json转换为通用的map
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	props := make(map[string]string)
+	flatten("", m, props)
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := bytes.NewBuffer(nil)
+	for _, k := range keys {
+		b.WriteString(escape(k, true))
+		b.WriteString("=")
+		b.WriteString(escape(props[k], false))
+		b.WriteString("\n")
+	}
+	return b.Bytes(), nil
+}
+
+func (p propertiesEncoder) Decode(d []byte, v interface{}) error {
+	props, err := Parse(d)
+	if err != nil {
+		return err
+	}
+	root := make(map[string]interface{})
+	for _, prop := range props {
+		if err := set(root, prop.Key, Convert(prop.Value)); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(finalize(root))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (p propertiesEncoder) String() string {
+	return "properties"
+}
+
+func NewEncoder() encoder.Encoder {
+	return propertiesEncoder{}
+}
+
+// Property 一个键值对
+type Property struct {
+	Key   string
+	Value string
+}
+
+// Parse 按java.util.Properties的规则解析，返回按出现顺序排列的键值对，重复的key保留最后一个
+func Parse(d []byte) ([]Property, error) {
+	lines := strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(string(d)), "\n")
+	index := make(map[string]int)
+	props := make([]Property, 0)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// 以奇数个反斜杠结尾的行与下一行拼接，下一行的前导空白被忽略
+		for continued(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if continued(line) {
+			line = line[:len(line)-1]
+		}
+		key, value := split(line)
+		k, err := unescape(key)
+		if err != nil {
+			return nil, fmt.Errorf("properties: line %d: %v", i+1, err)
+		}
+		val, err := unescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("properties: line %d: %v", i+1, err)
+		}
+		if idx, ok := index[k]; ok {
+			props[idx].Value = val
+			continue
+		}
+		index[k] = len(props)
+		props = append(props, Property{Key: k, Value: val})
+	}
+	return props, nil
+}
+
+func continued(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// split 以第一个未转义的'='、':'或空白分隔key与value
+func split(line string) (string, string) {
+	i := 0
+	for ; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			break
+		}
+	}
+	if i >= len(line) {
+		return line, ""
+	}
+	key, rest := line[:i], strings.TrimLeft(line[i:], " \t\f")
+	if line[i] == ' ' || line[i] == '\t' || line[i] == '\f' {
+		if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+			rest = strings.TrimLeft(rest[1:], " \t\f")
+		}
+	} else {
+		rest = strings.TrimLeft(line[i+1:], " \t\f")
+	}
+	return key, rest
+}
+
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 >= len(s) {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			i += 4
+			// 代理对
+			if r >= 0xD800 && r < 0xDC00 && i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+				if low, err := strconv.ParseUint(s[i+3:i+7], 16, 32); err == nil && low >= 0xDC00 && low < 0xE000 {
+					r = (r-0xD800)<<10 + (low - 0xDC00) + 0x10000
+					i += 6
+				}
+			}
+			b.WriteRune(rune(r))
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+func escape(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case ' ':
+			if key || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		default:
+			if r < 0x20 || r > 0x7e {
+				if r > 0xffff {
+					r1, r2 := utf16Surrogates(r)
+					fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+				} else if r != utf8.RuneError {
+					fmt.Fprintf(&b, `\u%04x`, r)
+				}
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func utf16Surrogates(r rune) (rune, rune) {
+	r -= 0x10000
+	return 0xD800 + (r>>10)&0x3ff, 0xDC00 + r&0x3ff
+}
+
+// Convert 将布尔值与能够无损往返的数字转换为对应类型，如"007"、"1.10"保持为字符串
+func Convert(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(i, 10) == s {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == s {
+		return f
+	}
+	return s
+}
+
+// MaxIndex 数组下标的上限，避免a[999999999]这样的key分配过大的数组
+const MaxIndex = 10000
+
+// segment key中的一段，index>=0时表示数组下标
+type segment struct {
+	name  string
+	index int
+}
+
+func parseKey(key string) ([]segment, error) {
+	segments := make([]segment, 0)
+	for _, part := range strings.Split(key, ".") {
+		name := part
+		var indexes []int
+		if i := strings.IndexByte(part, '['); i >= 0 && strings.HasSuffix(part, "]") {
+			name = part[:i]
+			for _, idx := range strings.Split(part[i+1:len(part)-1], "][") {
+				n, err := strconv.Atoi(idx)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("properties: invalid index in key %q", key)
+				}
+				if n > MaxIndex {
+					return nil, fmt.Errorf("properties: index of key %q exceeds %d", key, MaxIndex)
+				}
+				indexes = append(indexes, n)
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("properties: invalid key %q", key)
+		}
+		segments = append(segments, segment{name: name, index: -1})
+		for _, n := range indexes {
+			segments = append(segments, segment{index: n})
+		}
+	}
+	return segments, nil
+}
+
+// 解析过程中数组以下标为key的map表示，最后由finalize转换为slice
+type list map[int]interface{}
+
+// set 同一个key既有值又有子key时忽略值，同一个key既是数组又是map时返回错误
+func set(root map[string]interface{}, key string, value interface{}) error {
+	segments, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	var node interface{} = root
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		var next interface{}
+		if !last {
+			if segments[i+1].index >= 0 {
+				next = list{}
+			} else {
+				next = map[string]interface{}{}
+			}
+		}
+		var child interface{}
+		var ok bool
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if seg.index >= 0 {
+				return fmt.Errorf("properties: key %q conflicts with another key", key)
+			}
+			child, ok = n[seg.name]
+			if !ok {
+				if last {
+					n[seg.name] = value
+					return nil
+				}
+				n[seg.name] = next
+				child = next
+			}
+		case list:
+			if seg.index < 0 {
+				return fmt.Errorf("properties: key %q conflicts with another key", key)
+			}
+			child, ok = n[seg.index]
+			if !ok {
+				if last {
+					n[seg.index] = value
+					return nil
+				}
+				n[seg.index] = next
+				child = next
+			}
+		}
+		switch child.(type) {
+		case map[string]interface{}, list:
+			if last {
+				logger.Logf(logger.WarnLevel, "properties: value of key %q is ignored since it has child keys", key)
+				return nil
+			}
+		default:
+			if last {
+				return fmt.Errorf("properties: key %q conflicts with another key", key)
+			}
+			logger.Logf(logger.WarnLevel, "properties: child key %q overrides the value of its parent key", key)
+			child = next
+			switch n := node.(type) {
+			case map[string]interface{}:
+				n[seg.name] = next
+			case list:
+				n[seg.index] = next
+			}
+		}
+		node = child
+	}
+	return nil
+}
+
+func finalize(v interface{}) interface{} {
+	switch n := v.(type) {
+	case map[string]interface{}:
+		for k, child := range n {
+			n[k] = finalize(child)
+		}
+		return n
+	case list:
+		size := 0
+		for i := range n {
+			if i+1 > size {
+				size = i + 1
+			}
+		}
+		// 缺失的下标为nil
+		result := make([]interface{}, size)
+		for i, child := range n {
+			result[i] = finalize(child)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func flatten(prefix string, v interface{}, props map[string]string) {
+	switch n := v.(type) {
+	case map[string]interface{}:
+		for k, child := range n {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flatten(key, child, props)
+		}
+	case []interface{}:
+		for i, child := range n {
+			flatten(prefix+"["+strconv.Itoa(i)+"]", child, props)
+		}
+	case nil:
+		props[prefix] = ""
+	case string:
+		props[prefix] = n
+	default:
+		data, _ := json.Marshal(n)
+		props[prefix] = string(data)
+	}
+}
@@ -0,0 +1,119 @@
+package properties
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	data := `# comment
+! another comment
+server.port=8080
+server.name = gateway
+spring.datasource.url: jdbc:mysql://127.0.0.1:3306/db
+greeting \u4f60\u597d
+path=C:\\data\\logs
+multi=a,\
+      b,\
+      c
+servers[0].host=a
+servers[1].host=b
+ids[0]=1
+ids[1]=2
+version=1.10
+key\ with\ space=v
+empty
+`
+	var m map[string]interface{}
+	if err := NewEncoder().Decode([]byte(data), &m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"server":   map[string]interface{}{"port": float64(8080), "name": "gateway"},
+		"spring":   map[string]interface{}{"datasource": map[string]interface{}{"url": "jdbc:mysql://127.0.0.1:3306/db"}},
+		"greeting": "你好",
+		"path":     `C:\data\logs`,
+		"multi":    "a,b,c",
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a"},
+			map[string]interface{}{"host": "b"},
+		},
+		"ids":            []interface{}{float64(1), float64(2)},
+		"version":        "1.10",
+		"key with space": "v",
+		"empty":          "",
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("unexpected result %v", m)
+	}
+}
+
+func TestDecodeStruct(t *testing.T) {
+	var v struct {
+		Server struct {
+			Port  int  `json:"port"`
+			Debug bool `json:"debug"`
+		} `json:"server"`
+	}
+	if err := NewEncoder().Decode([]byte("server.port=8080\nserver.debug=true"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Server.Port != 8080 || !v.Server.Debug {
+		t.Fatalf("unexpected result %+v", v)
+	}
+}
+
+func TestDecodeConflict(t *testing.T) {
+	var m map[string]interface{}
+	if err := NewEncoder().Decode([]byte("a[0]=1\na.b=2"), &m); err == nil {
+		t.Fatal("expected conflict error")
+	}
+	// 既有值又有子key时子key优先，与顺序无关
+	cases := map[string]map[string]interface{}{
+		"a=1\na.b=2":     {"a": map[string]interface{}{"b": float64(2)}},
+		"a.b=2\na=1":     {"a": map[string]interface{}{"b": float64(2)}},
+		"a.b=1\na.b.c=2": {"a": map[string]interface{}{"b": map[string]interface{}{"c": float64(2)}}},
+		"a=1\na[0]=2":    {"a": []interface{}{float64(2)}},
+		"logging.level=info\nlogging.level.root=warn": {"logging": map[string]interface{}{"level": map[string]interface{}{"root": "warn"}}},
+	}
+	for content, expected := range cases {
+		var m map[string]interface{}
+		if err := NewEncoder().Decode([]byte(content), &m); err != nil {
+			t.Fatalf("%q: %v", content, err)
+		}
+		if !reflect.DeepEqual(m, expected) {
+			t.Fatalf("%q: unexpected result %#v", content, m)
+		}
+	}
+}
+
+func TestDecodeIndexLimit(t *testing.T) {
+	var m map[string]interface{}
+	if err := NewEncoder().Decode([]byte("a[999999999]=1"), &m); err == nil {
+		t.Fatal("expected index limit error")
+	}
+	if err := NewEncoder().Decode([]byte(fmt.Sprintf("a[%d]=1", MaxIndex)), &m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	e := NewEncoder()
+	src := map[string]interface{}{
+		"server":   map[string]interface{}{"port": float64(8080), "name": "gate way"},
+		"greeting": "你好=😀",
+		"ids":      []interface{}{"a", "b"},
+	}
+	data, err := e.Encode(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := e.Decode(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, src) {
+		t.Fatalf("unexpected result %v from %s", m, data)
+	}
+}
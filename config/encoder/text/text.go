@@ -0,0 +1,59 @@
+// text go-micro config的纯文本编解码，整个内容作为一个字符串
+package text
+
+import (
+	"errors"
+	"github.com/asim/go-micro/v3/config/encoder"
+)
+
+// DefaultKey 解码为map时内容所在的key
+const DefaultKey = "content"
+
+type textEncoder struct {
+	key string
+}
+
+func (t textEncoder) Encode(v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case string:
+		return []byte(val), nil
+	case []byte:
+		return val, nil
+	case map[string]interface{}:
+		if s, ok := val[t.key].(string); ok {
+			return []byte(s), nil
+		}
+	}
+	return nil, errors.New("text: unsupported value")
+}
+
+// Decode 可以直接解码到*string与*[]byte，解码到map时内容以key保存
+func (t textEncoder) Decode(d []byte, v interface{}) error {
+	switch val := v.(type) {
+	case *string:
+		*val = string(d)
+	case *[]byte:
+		*val = append((*val)[:0], d...)
+	case *map[string]interface{}:
+		if *val == nil {
+			*val = make(map[string]interface{})
+		}
+		(*val)[t.key] = string(d)
+	default:
+		return errors.New("text: unsupported value")
+	}
+	return nil
+}
+
+func (t textEncoder) String() string {
+	return "text"
+}
+
+func NewEncoder() encoder.Encoder {
+	return NewKeyEncoder(DefaultKey)
+}
+
+// NewKeyEncoder 解码为map时以key保存内容
+func NewKeyEncoder(key string) encoder.Encoder {
+	return textEncoder{key: key}
+}
@@ -0,0 +1,24 @@
+package text
+
+import (
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	e := NewEncoder()
+	var m map[string]interface{}
+	if err := e.Decode([]byte("hello"), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m[DefaultKey] != "hello" {
+		t.Fatalf("unexpected result %v", m)
+	}
+	var s string
+	if err := e.Decode([]byte("hello"), &s); err != nil || s != "hello" {
+		t.Fatalf("unexpected result %q, err: %v", s, err)
+	}
+	data, err := e.Encode(m)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected result %q, err: %v", data, err)
+	}
+}
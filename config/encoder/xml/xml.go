@@ -0,0 +1,179 @@
+// xml go-micro config的xml编解码
+// 根元素本身被忽略，其子元素作为顶层的key；属性以"-"为前缀，同时含有子元素与文本时文本的key为"#text"，
+// 同名的子元素解码为数组，叶子元素的值均为字符串
+package xml
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"github.com/asim/go-micro/v3/config/encoder"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	rootName   = "config"
+	attrPrefix = "-"
+	textKey    = "#text"
+)
+
+type xmlEncoder struct{}
+
+func (x xmlEncoder) Encode(v interface{}) ([]byte, error) {
+	// 先经过json转换为通用的map
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	b := bytes.NewBuffer(nil)
+	if err := encodeElement(b, rootName, m); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (x xmlEncoder) Decode(d []byte, v interface{}) error {
+	decoder := xml.NewDecoder(bytes.NewReader(d))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return errors.New("xml: missing root element")
+		}
+		if err != nil {
+			return err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			m, err := decodeElement(decoder, start)
+			if err != nil {
+				return err
+			}
+			// 空的根元素解码为空map
+			if s, ok := m.(string); ok && s == "" {
+				m = map[string]interface{}{}
+			}
+			data, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(data, v)
+		}
+	}
+}
+
+func (x xmlEncoder) String() string {
+	return "xml"
+}
+
+func NewEncoder() encoder.Encoder {
+	return xmlEncoder{}
+}
+
+func decodeElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	m := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		m[attrPrefix+attr.Name.Local] = attr.Value
+	}
+	var text strings.Builder
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			add(m, t.Name.Local, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m[textKey] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+// 同名元素合并为数组
+func add(m map[string]interface{}, key string, value interface{}) {
+	old, ok := m[key]
+	if !ok {
+		m[key] = value
+		return
+	}
+	if list, ok := old.([]interface{}); ok {
+		m[key] = append(list, value)
+		return
+	}
+	m[key] = []interface{}{old, value}
+}
+
+func encodeElement(b *bytes.Buffer, name string, v interface{}) error {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, item := range t {
+			if err := encodeElement(b, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString("<" + name)
+		for _, k := range keys {
+			if strings.HasPrefix(k, attrPrefix) {
+				b.WriteString(" " + k[len(attrPrefix):] + `="`)
+				xml.EscapeText(b, []byte(toString(t[k])))
+				b.WriteString(`"`)
+			}
+		}
+		b.WriteString(">")
+		for _, k := range keys {
+			switch {
+			case strings.HasPrefix(k, attrPrefix):
+			case k == textKey:
+				xml.EscapeText(b, []byte(toString(t[k])))
+			default:
+				if err := encodeElement(b, k, t[k]); err != nil {
+					return err
+				}
+			}
+		}
+		b.WriteString("</" + name + ">")
+		return nil
+	default:
+		b.WriteString("<" + name + ">")
+		xml.EscapeText(b, []byte(toString(v)))
+		b.WriteString("</" + name + ">")
+		return nil
+	}
+}
+
+func toString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,47 @@
+package xml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<config>
+	<server port="8080">gateway</server>
+	<hosts>a</hosts>
+	<hosts>b</hosts>
+	<empty/>
+</config>`
+	var m map[string]interface{}
+	if err := NewEncoder().Decode([]byte(data), &m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"server": map[string]interface{}{"-port": "8080", "#text": "gateway"},
+		"hosts":  []interface{}{"a", "b"},
+		"empty":  "",
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("unexpected result %v", m)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	e := NewEncoder()
+	src := map[string]interface{}{
+		"server": map[string]interface{}{"-port": "8080", "#text": "a<b"},
+		"hosts":  []interface{}{"a", "b"},
+	}
+	data, err := e.Encode(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := e.Decode(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, src) {
+		t.Fatalf("unexpected result %v from %s", m, data)
+	}
+}
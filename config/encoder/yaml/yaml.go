@@ -0,0 +1,25 @@
+// yaml go-micro config的yaml编解码，先转换为json，避免解码出map[interface{}]interface{}
+package yaml
+
+import (
+	"github.com/asim/go-micro/v3/config/encoder"
+	"github.com/ghodss/yaml"
+)
+
+type yamlEncoder struct{}
+
+func (y yamlEncoder) Encode(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (y yamlEncoder) Decode(d []byte, v interface{}) error {
+	return yaml.Unmarshal(d, v)
+}
+
+func (y yamlEncoder) String() string {
+	return "yaml"
+}
+
+func NewEncoder() encoder.Encoder {
+	return yamlEncoder{}
+}
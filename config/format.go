@@ -0,0 +1,57 @@
+package config
+
+import (
+	"path"
+	"strings"
+)
+
+// 各扩展名对应的go-micro ChangeSet.Format
+var extFormats = map[string]string{
+	".json":       "json",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".toml":       "toml",
+	".xml":        "xml",
+	".properties": "properties",
+	".txt":        "text",
+	".text":       "text",
+}
+
+// 格式别名
+var formatAliases = map[string]string{
+	"yml": "yaml",
+	"txt": "text",
+}
+
+func normalizeFormat(format string) string {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if f, ok := formatAliases[format]; ok {
+		return f
+	}
+	return format
+}
+
+// resolveFormat 按显式配置、DataId扩展名、配置内容的顺序确定配置格式
+// nacos-sdk-go v1.0.7的GetConfig与监听回调不返回配置类型，因此最后根据内容推断
+func resolveFormat(format, dataId, content string) string {
+	if format != "" {
+		return normalizeFormat(format)
+	}
+	if f, ok := extFormats[strings.ToLower(path.Ext(dataId))]; ok {
+		return f
+	}
+	return sniffFormat(content)
+}
+
+// sniffFormat 根据内容的首个字符推断格式，无法判断时视为yaml(json是yaml的子集)
+func sniffFormat(content string) string {
+	s := strings.TrimSpace(content)
+	switch {
+	case strings.HasPrefix(s, "{"), strings.HasPrefix(s, "["):
+		return "json"
+	case strings.HasPrefix(s, "<"):
+		return "xml"
+	default:
+		return "yaml"
+	}
+}
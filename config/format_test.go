@@ -0,0 +1,85 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/config/encoder/text"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config"
+	"testing"
+)
+
+func TestResolveFormat(t *testing.T) {
+	cases := []struct {
+		format, dataId, content, expected string
+	}{
+		{"yml", "gateway.json", `{}`, "yaml"},
+		{"", "gateway.json", "a: 1", "json"},
+		{"", "app.yml", "", "yaml"},
+		{"", "app.YAML", "", "yaml"},
+		{"", "app.toml", "", "toml"},
+		{"", "app.xml", "", "xml"},
+		{"", "application.properties", "", "properties"},
+		{"", "banner.txt", "", "text"},
+		{"", "gateway", ` {"a":1}`, "json"},
+		{"", "gateway", "<config/>", "xml"},
+		{"", "gateway", "a: 1", "yaml"},
+	}
+	for _, c := range cases {
+		if f := resolveFormat(c.format, c.dataId, c.content); f != c.expected {
+			t.Errorf("resolveFormat(%q, %q, %q) = %q, expected %q", c.format, c.dataId, c.content, f, c.expected)
+		}
+	}
+}
+
+func TestFormatDecode(t *testing.T) {
+	cases := map[string]string{
+		"decode.json":       `{"redis":{"addr":"127.0.0.1:6379","db":1}}`,
+		"decode.yaml":       "redis:\n  addr: 127.0.0.1:6379\n  db: 1\n",
+		"decode.toml":       "[redis]\naddr = \"127.0.0.1:6379\"\ndb = 1\n",
+		"decode.xml":        "<config><redis><addr>127.0.0.1:6379</addr><db>1</db></redis></config>",
+		"decode.properties": "redis.addr=127.0.0.1:6379\nredis.db=1",
+	}
+	client := mock.NewConfigClient()
+	for dataId, content := range cases {
+		publish(t, client, dataId, content)
+		c, err := config.NewConfig(config.WithReader(NewReader()), config.WithSource(newMockSource(client, dataId)))
+		if err != nil {
+			t.Fatalf("%s: %v", dataId, err)
+		}
+		if addr := c.Get("redis", "addr").String(""); addr != "127.0.0.1:6379" {
+			t.Errorf("%s: unexpected addr %q", dataId, addr)
+		}
+		if db := c.Get("redis", "db").Int(0); db != 1 {
+			t.Errorf("%s: unexpected db %d", dataId, db)
+		}
+		c.Close()
+	}
+}
+
+func TestFormatOption(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "gateway", "a: 1")
+	sour := newMockSource(client, "gateway",
+		mockParam("gateway", nacos.Format("YML")),
+	)
+	cs, err := sour.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs.Format != "yaml" {
+		t.Fatalf("unexpected format %q", cs.Format)
+	}
+}
+
+func TestFormatText(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "banner.txt", "hello nacos")
+	c, err := config.NewConfig(config.WithReader(NewReader()), config.WithSource(newMockSource(client, "banner.txt")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if s := c.Get(text.DefaultKey).String(""); s != "hello nacos" {
+		t.Fatalf("unexpected content %q", s)
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/asim/go-micro/v3/logger"
+	"net"
+	"path"
+	"strings"
+)
+
+// grayVariant 返回当前实例匹配的灰度名称，未开启或没有匹配时为空
+func grayVariant(n *nacosSource) string {
+	grayOpts, ok := n.options.Context.Value(nacos.GrayKey{}).([]nacos.GrayOption)
+	if !ok {
+		return ""
+	}
+	grayOptions := nacos.GrayOptions{
+		Attributes: make(map[string]string),
+	}
+	for _, grayOpt := range grayOpts {
+		grayOpt(&grayOptions)
+	}
+	if _, ok := grayOptions.Attributes["ip"]; !ok {
+		grayOptions.Attributes["ip"] = nacos.LocalIP()
+	}
+	for _, rule := range grayOptions.Rules {
+		if matchRule(rule.Match, grayOptions.Attributes) {
+			logger.Logf(logger.InfoLevel, "nacos config %s uses gray variant %s", n, rule.Variant)
+			return rule.Variant
+		}
+	}
+	return ""
+}
+
+func matchRule(match, attributes map[string]string) bool {
+	for key, patterns := range match {
+		value, ok := attributes[key]
+		if !ok {
+			return false
+		}
+		matched := false
+		for _, pattern := range strings.Split(patterns, ",") {
+			if matchValue(strings.TrimSpace(pattern), value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func matchValue(pattern, value string) bool {
+	if strings.Contains(pattern, "/") {
+		_, ipNet, err := net.ParseCIDR(pattern)
+		ip := net.ParseIP(value)
+		return err == nil && ip != nil && ipNet.Contains(ip)
+	}
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+// variantDataId 在扩展名前插入灰度名称，如gateway.yaml的canary灰度为gateway.canary.yaml
+func variantDataId(dataId, variant string) string {
+	ext := path.Ext(dataId)
+	if _, ok := extFormats[strings.ToLower(ext)]; !ok {
+		return dataId + "." + variant
+	}
+	return strings.TrimSuffix(dataId, ext) + "." + variant + ext
+}
+
+// graySource 匹配灰度规则时的配置源，灰度DataId存在时替换基础DataId，与nacos的beta发布一致
+// nacos-sdk-go v1.0.7的GetConfig不支持Tag与beta发布，因此灰度配置使用单独的DataId，
+// 在nacos控制台中需要按variantDataId的命名发布，而不是使用beta发布
+type graySource struct {
+	*mergedSource
+}
+
+func newGraySource(n *nacosSource, variant string) *graySource {
+	param := n.param
+	param.DataId = variantDataId(n.param.DataId, variant)
+	param.Optional = true
+	// 灰度DataId的扩展名可能无法识别，沿用基础DataId的格式
+	if param.Format == "" {
+		if f, ok := extFormats[strings.ToLower(path.Ext(n.param.DataId))]; ok {
+			param.Format = f
+		}
+	}
+	return &graySource{&mergedSource{
+		sources: []*nacosSource{n, n.clone(param)},
+		reader:  NewReader(),
+		sets:    make([]*source.ChangeSet, 2),
+		replace: true,
+	}}
+}
+
+// active 最近一次读取使用的配置源
+func (g *graySource) active() *nacosSource {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cs := g.sets[1]; cs != nil && len(cs.Data) > 0 {
+		return g.sources[1]
+	}
+	return g.sources[0]
+}
+
+// Write 写入当前使用的DataId
+func (g *graySource) Write(cs *source.ChangeSet) error {
+	return g.active().Write(cs)
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config"
+	"github.com/asim/go-micro/v3/config/source"
+	"strings"
+	"testing"
+)
+
+func TestGrayMatch(t *testing.T) {
+	attributes := map[string]string{"version": "v2.1.0", "cluster": "hz", "ip": "10.0.1.5"}
+	cases := []struct {
+		match   map[string]string
+		matched bool
+	}{
+		{map[string]string{}, true},
+		{map[string]string{"version": "v2.*"}, true},
+		{map[string]string{"version": "v1.*, v2.1.0"}, true},
+		{map[string]string{"version": "v2.*", "cluster": "sh"}, false},
+		{map[string]string{"ip": "10.0.0.0/16"}, true},
+		{map[string]string{"ip": "10.1.0.0/16"}, false},
+		{map[string]string{"zone": "*"}, false},
+	}
+	for _, c := range cases {
+		if matched := matchRule(c.match, attributes); matched != c.matched {
+			t.Errorf("match %v: expected %v, got %v", c.match, c.matched, matched)
+		}
+	}
+}
+
+func TestGrayVariantDataId(t *testing.T) {
+	cases := map[string]string{
+		"gateway.yaml": "gateway.canary.yaml",
+		"gateway":      "gateway.canary",
+		"gateway.v1":   "gateway.v1.canary",
+	}
+	for dataId, expected := range cases {
+		if v := variantDataId(dataId, "canary"); v != expected {
+			t.Errorf("variant of %s: expected %s, got %s", dataId, expected, v)
+		}
+	}
+}
+
+func TestGraySource(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "gateway.yaml", "a: base\nb: base\n")
+	newGray := func(version string) config.Config {
+		sour := newMockSource(client, "gateway.yaml",
+			nacos.ConfGray(
+				nacos.GrayAttribute("version", version),
+				nacos.GrayVariant("canary", map[string]string{"version": "v2.*"}),
+			),
+		)
+		c, err := config.NewConfig(config.WithSource(sour), config.WithReader(NewReader()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	// 灰度DataId不存在时使用基础DataId
+	c := newGray("v2.0.0")
+	if a := c.Get("a").String(""); a != "base" {
+		t.Fatalf("unexpected a %q", a)
+	}
+	c.Close()
+
+	publish(t, client, "gateway.canary.yaml", "a: canary\n")
+	c = newGray("v2.0.0")
+	defer c.Close()
+	// 灰度配置替换而不是覆盖基础配置
+	if a, b := c.Get("a").String(""), c.Get("b").String(""); a != "canary" || b != "" {
+		t.Fatalf("unexpected a %q, b %q", a, b)
+	}
+	// 不匹配规则的实例不受灰度影响
+	other := newGray("v1.0.0")
+	defer other.Close()
+	if a := other.Get("a").String(""); a != "base" {
+		t.Fatalf("unexpected a %q", a)
+	}
+}
+
+func TestGrayForward(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "gateway.yaml", "a: base\n")
+	publish(t, client, "gateway.canary.yaml", "a: canary\n")
+	sour := newMockSource(client, "gateway.yaml",
+		nacos.ConfGray(nacos.GrayVariant("canary", nil)),
+	)
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	// Write与Delete作用于当前使用的灰度DataId
+	if err := sour.Write(&source.ChangeSet{Data: []byte("a: written\n"), Format: "yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	if content := getConfig(t, client, "gateway.canary.yaml"); content != "a: written\n" {
+		t.Fatalf("unexpected canary content %q", content)
+	}
+	if err := Delete(sour); err != nil {
+		t.Fatal(err)
+	}
+	if content := getConfig(t, client, "gateway.yaml"); content != "a: base\n" {
+		t.Fatalf("unexpected base content %q", content)
+	}
+	cs, err := sour.Read()
+	if err != nil || !strings.Contains(string(cs.Data), "base") {
+		t.Fatalf("expected base config after deleting variant, got %v, err: %v", cs, err)
+	}
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/internal/snapfile"
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/asim/go-micro/v3/logger"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+const defaultHistorySize = 10
+
+// ErrRevisionNotFound 本地历史中没有指定的版本
+var ErrRevisionNotFound = errors.New("nacos config revision not found")
+
+// Revision 一次交付的配置，Data为nacos中去掉签名后、解密与占位符替换前的内容，固定时重新处理，明文不落盘
+type Revision struct {
+	// Data的sha256
+	Checksum  string    `json:"checksum"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      string    `json:"data"`
+}
+
+type historyFile struct {
+	// 固定的版本，为空表示未固定
+	Pinned    string     `json:"pinned,omitempty"`
+	Revisions []Revision `json:"revisions"`
+}
+
+// history 每个配置一个文件，按时间顺序保存最近size个版本
+type history struct {
+	dir   string
+	size  int
+	mu    sync.Mutex
+	files map[string]*historyFile
+}
+
+func newHistory(dir string, size int) *history {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &history{
+		dir:   dir,
+		size:  size,
+		files: make(map[string]*historyFile),
+	}
+}
+
+// file 首次访问时从磁盘加载，调用方持有mu
+func (h *history) file(key string) *historyFile {
+	if f, ok := h.files[key]; ok {
+		return f
+	}
+	f := &historyFile{}
+	if data, err := ioutil.ReadFile(snapfile.Path(h.dir, key)); err == nil {
+		if err := json.Unmarshal(data, f); err != nil {
+			logger.Logf(logger.WarnLevel, "nacos load config history %s failed, err:%v", key, err)
+			f = &historyFile{}
+		}
+	}
+	h.files[key] = f
+	return f
+}
+
+// save 调用方持有mu，写盘失败时只保留内存中的历史
+func (h *history) save(key string, f *historyFile) {
+	data, err := json.Marshal(f)
+	if err == nil {
+		err = snapfile.WriteFile(snapfile.Path(h.dir, key), data)
+	}
+	if err != nil {
+		logger.Logf(logger.WarnLevel, "nacos save config history %s failed, err:%v", key, err)
+	}
+}
+
+func (h *history) record(key string, rev Revision) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.file(key)
+	if n := len(f.Revisions); n > 0 && f.Revisions[n-1].Checksum == rev.Checksum {
+		return
+	}
+	f.Revisions = append(f.Revisions, rev)
+	if n := len(f.Revisions); n > h.size {
+		f.Revisions = append([]Revision(nil), f.Revisions[n-h.size:]...)
+	}
+	h.save(key, f)
+}
+
+// list 按从新到旧的顺序返回
+func (h *history) list(key string) []Revision {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	revisions := h.file(key).Revisions
+	list := make([]Revision, len(revisions))
+	for i, rev := range revisions {
+		list[len(revisions)-1-i] = rev
+	}
+	return list
+}
+
+func (h *history) pin(key, checksum string) (Revision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.file(key)
+	for _, rev := range f.Revisions {
+		if rev.Checksum == checksum {
+			f.Pinned = checksum
+			h.save(key, f)
+			return rev, nil
+		}
+	}
+	return Revision{}, ErrRevisionNotFound
+}
+
+func (h *history) unpin(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.file(key)
+	if f.Pinned == "" {
+		return
+	}
+	f.Pinned = ""
+	h.save(key, f)
+}
+
+// pinned 返回固定的版本，版本已不在历史中时视为未固定
+func (h *history) pinned(key string) (Revision, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.file(key)
+	if f.Pinned == "" {
+		return Revision{}, false
+	}
+	for _, rev := range f.Revisions {
+		if rev.Checksum == f.Pinned {
+			return rev, true
+		}
+	}
+	return Revision{}, false
+}
+
+// recordHistory 记录一次交付，空内容(配置被删除)不记录
+func (n *nacosSource) recordHistory(cs *source.ChangeSet, body string) {
+	if n.history == nil || body == "" {
+		return
+	}
+	n.history.record(n.snapshotKey(), Revision{
+		Checksum:  checksum(body),
+		Timestamp: cs.Timestamp,
+		Data:      body,
+	})
+}
+
+// loadPinned 返回固定版本对应的ChangeSet，未固定时返回nil
+func (n *nacosSource) loadPinned() (*source.ChangeSet, error) {
+	if n.history == nil {
+		return nil, nil
+	}
+	rev, ok := n.history.pinned(n.snapshotKey())
+	if !ok {
+		return nil, nil
+	}
+	// 历史中保存的是校验通过的内容
+	cs, _, err := n.process(n.param.DataId, rev.Data, true)
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+func historyOf(src source.Source) (*nacosSource, error) {
+	n, ok := nacosSourceOf(src)
+	if !ok {
+		return nil, errors.New("not a nacos config source, use Entry to select a DataId of a merged source")
+	}
+	if n.history == nil {
+		return nil, errors.New("nacos config history isn't enabled")
+	}
+	return n, nil
+}
+
+// Entry 返回NewMergedSource、NewDefaultSource或灰度配置源中DataId对应的项，用于History、Pin、Unpin与Delete
+// 多个命名空间中存在相同的DataId时返回优先级最高的项
+func Entry(src source.Source, dataId string) (source.Source, error) {
+	var sources []*nacosSource
+	switch s := src.(type) {
+	case *nacosSource:
+		sources = []*nacosSource{s}
+	case *mergedSource:
+		sources = s.sources
+	case *graySource:
+		sources = s.sources
+	case *overlaySource:
+		return Entry(s.base, dataId)
+	default:
+		return nil, errors.New("not a nacos config source")
+	}
+	for i := len(sources) - 1; i >= 0; i-- {
+		if sources[i].param.DataId == dataId {
+			return sources[i], nil
+		}
+	}
+	return nil, fmt.Errorf("nacos config source doesn't contain %s", dataId)
+}
+
+// History 返回NewSource创建的配置源在本地保存的版本，从新到旧
+func History(src source.Source) ([]Revision, error) {
+	n, err := historyOf(src)
+	if err != nil {
+		return nil, err
+	}
+	return n.history.list(n.snapshotKey()), nil
+}
+
+// Pin 将配置源固定到历史中的版本并交付给所有watcher，之后忽略nacos的推送直到Unpin
+// 固定状态保存在历史文件中，重启后仍然生效
+func Pin(src source.Source, checksum string) (err error) {
+	n, err := historyOf(src)
+	if err != nil {
+		return err
+	}
+	done := n.hooks.Start(n.options.Context, hook.ConfigPin, map[string]string{"data_id": n.param.DataId, "group": n.param.Group, "checksum": checksum})
+	defer func() { done(err) }()
+	n.pinMu.Lock()
+	defer n.pinMu.Unlock()
+	rev, err := n.history.pin(n.snapshotKey(), checksum)
+	if err != nil {
+		return err
+	}
+	cs, _, err := n.process(n.param.DataId, rev.Data, true)
+	if err != nil {
+		n.history.unpin(n.snapshotKey())
+		return fmt.Errorf("process revision %s failed: %v", checksum, err)
+	}
+	logger.Logf(logger.WarnLevel, "nacos config %s pinned to revision %s", n, checksum)
+	n.setChecksum(cs.Checksum)
+	n.broadcast(cs)
+	return nil
+}
+
+// Unpin 取消固定，重新从nacos读取配置并交付给所有watcher
+func Unpin(src source.Source) (err error) {
+	n, err := historyOf(src)
+	if err != nil {
+		return err
+	}
+	done := n.hooks.Start(n.options.Context, hook.ConfigUnpin, map[string]string{"data_id": n.param.DataId, "group": n.param.Group})
+	defer func() { done(err) }()
+	n.pinMu.Lock()
+	defer n.pinMu.Unlock()
+	n.history.unpin(n.snapshotKey())
+	logger.Logf(logger.InfoLevel, "nacos config %s unpinned", n)
+	content, err := n.config.GetConfig(n.param.ConfigParam)
+	if err != nil {
+		// 之后的推送会正常交付
+		return err
+	}
+	n.setMd5(content)
+	cs, body, err := n.process(n.param.DataId, content, false)
+	if err != nil {
+		return err
+	}
+	n.setChecksum(cs.Checksum)
+	n.saveSnapshot(body)
+	n.recordHistory(cs, body)
+	n.broadcast(cs)
+	return nil
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config/source"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func nextChangeSet(t *testing.T, w source.Watcher) *source.ChangeSet {
+	t.Helper()
+	ch := make(chan *source.ChangeSet, 1)
+	go func() {
+		if cs, err := w.Next(); err == nil {
+			ch <- cs
+		}
+	}()
+	select {
+	case cs := <-ch:
+		return cs
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for change")
+	}
+	return nil
+}
+
+func TestHistoryPin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := mock.NewConfigClient()
+	var ops []string
+	hooks := hook.Hooks{hook.Funcs{AfterFunc: func(op *hook.Operation) {
+		if op.Name == hook.ConfigPin || op.Name == hook.ConfigUnpin {
+			ops = append(ops, op.Name)
+		}
+	}}}
+	publish(t, client, "history.yaml", "v: 1\n")
+	sour := newMockSource(client, "history.yaml", nacos.ConfHistory(nacos.HistoryDir(dir), nacos.HistorySize(2)), nacos.ConfHooks(hooks...))
+	first, err := sour.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	publish(t, client, "history.yaml", "v: 2\n")
+	if cs := nextChangeSet(t, w); string(cs.Data) != "v: 2\n" {
+		t.Fatalf("unexpected data %q", cs.Data)
+	}
+	revisions, err := History(sour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[1].Data != string(first.Data) {
+		t.Fatalf("unexpected revisions %v", revisions)
+	}
+
+	if err := Pin(sour, "unknown"); err != ErrRevisionNotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := Pin(sour, revisions[1].Checksum); err != nil {
+		t.Fatal(err)
+	}
+	if cs := nextChangeSet(t, w); string(cs.Data) != "v: 1\n" {
+		t.Fatalf("unexpected data %q", cs.Data)
+	}
+	// 固定期间忽略推送，重启后仍然固定
+	publish(t, client, "history.yaml", "v: 3\n")
+	restarted := newMockSource(client, "history.yaml", nacos.ConfHistory(nacos.HistoryDir(dir), nacos.HistorySize(2)))
+	if cs, err := restarted.Read(); err != nil || string(cs.Data) != "v: 1\n" {
+		t.Fatalf("unexpected read %v, %v", cs, err)
+	}
+
+	if err := Unpin(sour); err != nil {
+		t.Fatal(err)
+	}
+	if cs := nextChangeSet(t, w); string(cs.Data) != "v: 3\n" {
+		t.Fatalf("unexpected data %q", cs.Data)
+	}
+	// 只保留最近的两个版本
+	if revisions, _ := History(sour); len(revisions) != 2 || string(revisions[0].Data) != "v: 3\n" {
+		t.Fatalf("unexpected revisions %v", revisions)
+	}
+	if len(ops) != 3 || ops[2] != hook.ConfigUnpin {
+		t.Fatalf("unexpected hook operations %v", ops)
+	}
+}
+
+func TestHistoryRawContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("NACOS_TEST_HISTORY", "plain")
+	defer os.Unsetenv("NACOS_TEST_HISTORY")
+	client := mock.NewConfigClient()
+	publish(t, client, "history.yaml", "v: ${NACOS_TEST_HISTORY}\n")
+	sour := newMockSource(client, "history.yaml",
+		nacos.ConfHistory(nacos.HistoryDir(dir)),
+		nacos.ConfInterpolation(),
+	)
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	// 历史中保存占位符替换前的内容
+	revisions, err := History(sour)
+	if err != nil || len(revisions) != 1 || revisions[0].Data != "v: ${NACOS_TEST_HISTORY}\n" {
+		t.Fatalf("unexpected revisions %v, err: %v", revisions, err)
+	}
+	data, err := ioutil.ReadDir(dir)
+	if err != nil || len(data) != 1 {
+		t.Fatalf("unexpected history files %v, err: %v", data, err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, data[0].Name()))
+	if err != nil || strings.Contains(string(content), "plain") {
+		t.Fatalf("history contains interpolated value: %s, err: %v", content, err)
+	}
+}
+
+func TestHistoryMergedEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := mock.NewConfigClient()
+	publish(t, client, "common.yaml", "a: common\n")
+	publish(t, client, "history.yaml", "v: 1\n")
+	sour := NewMergedSource(mockOptions(client,
+		nacos.ConfEntry(nacos.DataId("common.yaml"), nacos.Group("DEFAULT_GROUP")),
+		nacos.ConfEntry(nacos.DataId("history.yaml"), nacos.Group("DEFAULT_GROUP")),
+		nacos.ConfHistory(nacos.HistoryDir(dir)),
+	)...)
+	if _, err := History(sour); err == nil {
+		t.Fatal("expected error for merged source")
+	}
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := Entry(sour, "history.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := History(entry)
+	if err != nil || len(revisions) != 1 {
+		t.Fatalf("unexpected revisions %v, err: %v", revisions, err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	publish(t, client, "history.yaml", "v: 2\n")
+	nextChangeSet(t, w)
+	// 固定某一项后合并结果使用该项的固定版本
+	if err := Pin(entry, revisions[0].Checksum); err != nil {
+		t.Fatal(err)
+	}
+	if cs := nextChangeSet(t, w); !strings.Contains(string(cs.Data), `"v":1`) {
+		t.Fatalf("unexpected data %s", cs.Data)
+	}
+	if _, err := Entry(sour, "unknown.yaml"); err == nil {
+		t.Fatal("expected error for unknown DataId")
+	}
+}
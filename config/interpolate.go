@@ -0,0 +1,190 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/config/encoder/properties"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// 匹配"$${"(转义，输出"${")与"${expr}"，不支持嵌套
+var placeholder = regexp.MustCompile(`\$\$\{|\$\{([^{}]*)\}`)
+
+// interpolator 替换配置中的占位符，${expr}按以下顺序解析:
+// 1. expr为"prefix:key"且prefix注册了Resolver时，由Resolver解析key
+// 2. 环境变量
+// 3. 同一配置中以"."分隔的key
+// 4. 第一个":"之后的默认值，如${PORT:8080}，":-"与":"相同，如${PORT:-8080}
+// 配置能够解析时在解析后的值中替换并重新编码，避免值中的引号、换行等破坏配置格式，否则按文本替换
+type interpolator struct {
+	resolvers map[string]nacos.Resolver
+}
+
+func newInterpolator(resolvers map[string]nacos.Resolver) *interpolator {
+	return &interpolator{resolvers: resolvers}
+}
+
+func (i *interpolator) interpolate(format, content string) (string, error) {
+	if !placeholder.MatchString(content) {
+		return content, nil
+	}
+	r := &resolution{
+		interpolator: i,
+		format:       format,
+		content:      content,
+		visiting:     make(map[string]bool),
+	}
+	e, ok := encoderOf(format)
+	if !ok || r.document() == nil {
+		return r.expand(content)
+	}
+	v, err := mapStrings(r.doc, r.scalar)
+	if err != nil {
+		return "", err
+	}
+	data, err := e.Encode(v)
+	if err != nil {
+		return "", fmt.Errorf("encode %s config failed: %v", format, err)
+	}
+	return string(data), nil
+}
+
+// resolution 一次替换的状态
+type resolution struct {
+	*interpolator
+	format  string
+	content string
+	// 解析后的配置，第一次使用时才解析
+	doc     map[string]interface{}
+	decoded bool
+	// 正在解析的key，用于检测循环引用
+	visiting map[string]bool
+}
+
+// document 解析后的配置，无法解析时返回nil
+func (r *resolution) document() map[string]interface{} {
+	if !r.decoded {
+		r.decoded = true
+		if e, ok := encoderOf(r.format); ok {
+			var doc map[string]interface{}
+			if err := e.Decode([]byte(r.content), &doc); err == nil {
+				r.doc = doc
+			}
+		}
+	}
+	return r.doc
+}
+
+// scalar 值只有一个占位符时，yaml与properties按字面量的规则转换为布尔值或数字，
+// 如port: ${PORT:-8080}，Resolver的结果(如密码)始终是字符串
+func (r *resolution) scalar(s string) (interface{}, error) {
+	m := placeholder.FindStringSubmatchIndex(s)
+	if m == nil || m[0] != 0 || m[1] != len(s) || m[2] < 0 {
+		return r.expand(s)
+	}
+	v, resolved, err := r.resolve(s[m[2]:m[3]])
+	if err != nil {
+		return nil, err
+	}
+	if !resolved && (r.format == "yaml" || r.format == "properties") {
+		return properties.Convert(v), nil
+	}
+	return v, nil
+}
+
+func (r *resolution) expand(s string) (string, error) {
+	matches := placeholder.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		last = m[1]
+		if m[2] < 0 {
+			b.WriteString("${")
+			continue
+		}
+		v, _, err := r.resolve(s[m[2]:m[3]])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(v)
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// resolve 解析占位符，resolved表示结果来自Resolver
+func (r *resolution) resolve(expr string) (v string, resolved bool, err error) {
+	name, def, hasDef := expr, "", false
+	if idx := strings.IndexByte(expr, ':'); idx >= 0 {
+		if resolver, ok := r.resolvers[strings.TrimSpace(expr[:idx])]; ok {
+			if v, err = resolver(expr[idx+1:]); err != nil {
+				return "", false, fmt.Errorf("resolve ${%s} failed: %v", expr, err)
+			}
+			return v, true, nil
+		}
+		// 默认值从第一个':'之后开始，可以包含':'，如${REDIS_ADDR:127.0.0.1:6379}
+		name, def, hasDef = expr[:idx], strings.TrimPrefix(expr[idx+1:], "-"), true
+	}
+	name = strings.TrimSpace(name)
+	if v, ok := os.LookupEnv(name); ok {
+		return v, false, nil
+	}
+	if v, ok := r.lookup(name); ok {
+		if r.visiting[name] {
+			return "", false, fmt.Errorf("circular reference of ${%s}", name)
+		}
+		r.visiting[name] = true
+		defer delete(r.visiting, name)
+		v, err = r.expand(v)
+		return v, false, err
+	}
+	if hasDef {
+		return def, false, nil
+	}
+	return "", false, fmt.Errorf("unresolved placeholder ${%s}", expr)
+}
+
+// lookup 在同一配置中查找key，配置无法解析时视为不存在
+func (r *resolution) lookup(key string) (string, bool) {
+	var v interface{} = r.document()
+	for _, part := range strings.Split(key, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if v, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case map[string]interface{}, []interface{}, nil:
+		return "", false
+	default:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+}
+
+// FileResolver 以文件内容替换占位符，去掉末尾的换行，如${file:/etc/secrets/db_password}
+func FileResolver() nacos.Resolver {
+	return func(path string) (string, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+}
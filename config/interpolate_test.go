@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/ghodss/yaml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("NACOS_TEST_HOST", "10.0.0.1")
+	defer os.Unsetenv("NACOS_TEST_HOST")
+	dir, err := ioutil.TempDir("", "interpolate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secretFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	i := newInterpolator(map[string]nacos.Resolver{
+		"secret": func(key string) (string, error) {
+			if key == "db_password" {
+				return "p@ss", nil
+			}
+			return "", errors.New("unknown secret")
+		},
+		"file": FileResolver(),
+	})
+	content := `redis:
+  host: ${NACOS_TEST_HOST}
+  port: ${NACOS_TEST_PORT:-6379}
+  addr: ${redis.host}:${redis.port}
+db:
+  password: ${secret:db_password}
+  key: ${file:` + secretFile + `}
+literal: $${NACOS_TEST_HOST}
+price: $$5
+`
+	result, err := i.interpolate("yaml", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `redis:
+  host: 10.0.0.1
+  port: 6379
+  addr: 10.0.0.1:6379
+db:
+  password: p@ss
+  key: s3cret
+literal: ${NACOS_TEST_HOST}
+price: $$5
+`
+	assertYAML(t, result, expected)
+}
+
+func assertYAML(t *testing.T, result, expected string) {
+	t.Helper()
+	var got, want map[string]interface{}
+	if err := yaml.Unmarshal([]byte(result), &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result:\n%s", result)
+	}
+}
+
+func TestInterpolateEscape(t *testing.T) {
+	i := newInterpolator(map[string]nacos.Resolver{
+		"secret": func(key string) (string, error) {
+			return "123456", nil
+		},
+		"raw": func(key string) (string, error) {
+			return "a\"b: c\n# d", nil
+		},
+	})
+	// 值中的引号、冒号、换行与#不破坏配置格式，Resolver的结果保持为字符串
+	cases := map[string]string{
+		"yaml":       "password: ${secret:db}\nvalue: ${raw:x}\n",
+		"json":       `{"password": "${secret:db}", "value": "${raw:x}"}`,
+		"properties": "password=${secret:db}\nvalue=${raw:x}\n",
+	}
+	// properties的值在解码时总是按字面量转换
+	expected := map[string]interface{}{"yaml": "123456", "json": "123456", "properties": float64(123456)}
+	for format, content := range cases {
+		result, err := i.interpolate(format, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, _ := encoderOf(format)
+		var m map[string]interface{}
+		if err := e.Decode([]byte(result), &m); err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, result)
+		}
+		if m["password"] != expected[format] || m["value"] != "a\"b: c\n# d" {
+			t.Fatalf("%s: unexpected result %#v", format, m)
+		}
+	}
+}
+
+func TestInterpolateError(t *testing.T) {
+	i := newInterpolator(map[string]nacos.Resolver{
+		"secret": func(key string) (string, error) {
+			return "", errors.New("unknown secret")
+		},
+	})
+	cases := map[string]string{
+		"a: ${NACOS_TEST_MISSING}":    "unresolved placeholder",
+		"a: ${secret:x}":              "unknown secret",
+		"a: ${b}\nb: ${a}\n":          "circular reference",
+		"a: ${NACOS_TEST_MISSING:-}x": "",
+	}
+	for content, expected := range cases {
+		_, err := i.interpolate("yaml", content)
+		if expected == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", content, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected error containing %q, got %v", content, expected, err)
+		}
+	}
+}
+
+func TestInterpolateDefault(t *testing.T) {
+	i := newInterpolator(map[string]nacos.Resolver{
+		"secret": func(key string) (string, error) {
+			return "p@ss", nil
+		},
+	})
+	// 未注册的前缀按默认值处理，默认值可以包含":"
+	result, err := i.interpolate("yaml", "addr: ${REDIS_ADDR:127.0.0.1:6379}\nport: ${NACOS_TEST_PORT:-6379}\ntoken: ${vault:db_password}\npassword: ${secret:db}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertYAML(t, result, "addr: 127.0.0.1:6379\nport: 6379\ntoken: db_password\npassword: p@ss\n")
+}
+
+func TestInterpolateWatch(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "interpolate.yaml", "a: ${NACOS_TEST_MISSING:-1}")
+	sour := newMockSource(client, "interpolate.yaml",
+		nacos.ConfInterpolation(),
+	)
+	cs, err := sour.Read()
+	if err != nil || string(cs.Data) != "a: 1\n" {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	// 无法解析的推送被拒绝
+	publish(t, client, "interpolate.yaml", "a: ${NACOS_TEST_MISSING}")
+	publish(t, client, "interpolate.yaml", "a: ${NACOS_TEST_MISSING:-2}")
+	cs, err = w.Next()
+	if err != nil || string(cs.Data) != "a: 2\n" {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+}
@@ -0,0 +1,290 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/asim/go-micro/v3/config/reader"
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mergedSource 将多个DataId合并为一个配置源，后面的项覆盖前面的项
+type mergedSource struct {
+	sources []*nacosSource
+	reader  reader.Reader
+	mu      sync.Mutex
+	// 各项最近一次的内容，读取失败的可选项为nil
+	sets []*source.ChangeSet
+	// 为true时不合并，使用最后一个有内容的项
+	replace bool
+}
+
+// NewMergedSource 由nacos.ConfEntry指定各项，其余配置与NewSource相同
+func NewMergedSource(opts ...source.Option) source.Source {
+	m, err := newMergedSource(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func newMergedSource(opts ...source.Option) (*mergedSource, error) {
+	base := &nacosSource{
+		client:  nacos.ClientOptions{ClientConfig: *constant.NewClientConfig()},
+		server:  make([]nacos.ServerOptions, 0),
+		options: source.NewOptions(),
+	}
+	if err := configureClient(base, opts...); err != nil {
+		return nil, err
+	}
+	entries, _ := base.options.Context.Value(nacos.ConfEntryKey{}).([][]nacos.ConfigOption)
+	namespaces, _ := base.options.Context.Value(nacos.NamespacesKey{}).([]string)
+	// 指定多个命名空间时可以只使用ConfParam
+	if param, ok := base.options.Context.Value(nacos.ConfParamKey{}).([]nacos.ConfigOption); ok && len(entries) == 0 && len(namespaces) > 0 {
+		entries = [][]nacos.ConfigOption{param}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("missing confEntry options")
+	}
+	params := expandNamespaces(entries, namespaces)
+
+	serverConfigs := make([]constant.ServerConfig, 0, len(base.server))
+	for _, s := range base.server {
+		serverConfigs = append(serverConfigs, s.ServerConfig)
+	}
+	// 每个命名空间一个configClient
+	configs := map[string]config_client.IConfigClient{base.client.NamespaceId: base.config}
+	if injected, ok := base.options.Context.Value(nacos.NamespaceClientKey{}).(map[string]config_client.IConfigClient); ok {
+		for ns, config := range injected {
+			configs[ns] = config
+		}
+	}
+
+	m := &mergedSource{
+		reader: NewReader(),
+		sets:   make([]*source.ChangeSet, len(params)),
+	}
+	for _, param := range params {
+		src := base.clone(param)
+		if src.param.DataId == "" || src.param.Group == "" {
+			return nil, errors.New("missing dataId or group of confEntry")
+		}
+		if ns := src.param.NamespaceId; ns != "" {
+			src.client.NamespaceId = ns
+			config, ok := configs[ns]
+			if !ok {
+				if len(serverConfigs) == 0 {
+					return nil, fmt.Errorf("namespace %s of %s requires server options", ns, src.param.DataId)
+				}
+				var err error
+				if config, err = newConfigClient(src.client.ClientConfig, serverConfigs); err != nil {
+					return nil, err
+				}
+				configs[ns] = config
+			}
+			src.config = config
+		}
+		m.sources = append(m.sources, src)
+	}
+	return m, nil
+}
+
+// expandNamespaces 依次在每个命名空间中展开未指定命名空间的项，命名空间的顺序优先于项的顺序
+func expandNamespaces(entries [][]nacos.ConfigOption, namespaces []string) []nacos.ConfigOptions {
+	params := make([]nacos.ConfigOptions, 0, len(entries))
+	for _, entry := range entries {
+		var param nacos.ConfigOptions
+		for _, opt := range entry {
+			opt(&param)
+		}
+		params = append(params, param)
+	}
+	if len(namespaces) == 0 {
+		return params
+	}
+	// 指定了命名空间的项只在对应的命名空间中加入，命名空间不在列表中时在第一个命名空间中加入
+	listed := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		listed[ns] = true
+	}
+	expanded := make([]nacos.ConfigOptions, 0, len(params)*len(namespaces))
+	for i, ns := range namespaces {
+		for _, param := range params {
+			switch {
+			case param.NamespaceId == "":
+				param.NamespaceId = ns
+			case param.NamespaceId == ns, i == 0 && !listed[param.NamespaceId]:
+			default:
+				continue
+			}
+			expanded = append(expanded, param)
+		}
+	}
+	return expanded
+}
+
+func (m *mergedSource) Read() (*source.ChangeSet, error) {
+	sets := make([]*source.ChangeSet, len(m.sources))
+	// 各RequiredGroup中存在的项，以及组中的项
+	found := make(map[string]bool)
+	groups := make(map[string][]string)
+	for i, src := range m.sources {
+		group := src.param.RequiredGroup
+		if group != "" {
+			groups[group] = append(groups[group], src.String())
+		}
+		cs, err := src.Read()
+		if err != nil {
+			// 可选项只忽略配置不存在，nacos不可用时仍然返回错误
+			if !(src.param.Optional || group != "") || !isNotFound(err) {
+				return nil, fmt.Errorf("read %s failed: %v", src, err)
+			}
+			logger.Logf(logger.WarnLevel, "nacos read optional config %s failed, err:%v", src, err)
+			continue
+		}
+		found[group] = true
+		sets[i] = cs
+	}
+	for group, names := range groups {
+		if !found[group] {
+			return nil, fmt.Errorf("read %s failed: none of %s exists", group, strings.Join(names, ", "))
+		}
+	}
+	m.mu.Lock()
+	m.sets = sets
+	m.mu.Unlock()
+	return m.merge(sets)
+}
+
+// update 更新某一项的内容并返回合并后的结果
+func (m *mergedSource) update(i int, cs *source.ChangeSet) (*source.ChangeSet, error) {
+	m.mu.Lock()
+	m.sets[i] = cs
+	sets := append([]*source.ChangeSet(nil), m.sets...)
+	m.mu.Unlock()
+	return m.merge(sets)
+}
+
+func (m *mergedSource) merge(sets []*source.ChangeSet) (*source.ChangeSet, error) {
+	if m.replace {
+		sets = lastNonEmpty(sets)
+	}
+	merged, err := m.reader.Merge(sets...)
+	if err != nil {
+		return nil, err
+	}
+	merged.Source = m.String()
+	for _, cs := range sets {
+		if IsStale(cs) {
+			merged.Source += StaleSuffix
+			break
+		}
+	}
+	merged.Timestamp = time.Now()
+	merged.Checksum = merged.Sum()
+	return merged, nil
+}
+
+func lastNonEmpty(sets []*source.ChangeSet) []*source.ChangeSet {
+	for i := len(sets) - 1; i >= 0; i-- {
+		if sets[i] != nil && len(sets[i].Data) > 0 {
+			return sets[i : i+1]
+		}
+	}
+	return nil
+}
+
+// Write 合并后的配置无法拆分回各项，需要写入时对单个DataId使用NewSource
+func (m *mergedSource) Write(cs *source.ChangeSet) error {
+	return errors.New("nacos merged config doesn't implement Write method")
+}
+
+func (m *mergedSource) Watch() (source.Watcher, error) {
+	return newMergedWatcher(m)
+}
+
+func (m *mergedSource) String() string {
+	names := make([]string, len(m.sources))
+	for i, src := range m.sources {
+		names[i] = src.String()
+	}
+	return strings.Join(names, ",")
+}
+
+type mergedWatcher struct {
+	src      *mergedSource
+	watchers []source.Watcher
+	confChan chan *source.ChangeSet
+	exit     chan struct{}
+}
+
+func newMergedWatcher(m *mergedSource) (source.Watcher, error) {
+	watcher := &mergedWatcher{
+		src:      m,
+		confChan: make(chan *source.ChangeSet, 10),
+		exit:     make(chan struct{}),
+	}
+	for i, src := range m.sources {
+		w, err := src.Watch()
+		if err != nil {
+			watcher.Stop()
+			return nil, err
+		}
+		watcher.watchers = append(watcher.watchers, w)
+		go watcher.run(i, w)
+	}
+	return watcher, nil
+}
+
+func (w *mergedWatcher) run(i int, watcher source.Watcher) {
+	for {
+		cs, err := watcher.Next()
+		if err != nil {
+			return
+		}
+		merged, err := w.src.update(i, cs)
+		if err != nil {
+			logger.Logf(logger.ErrorLevel, "nacos merge config %s failed, err:%v", w.src.sources[i], err)
+			continue
+		}
+		select {
+		case w.confChan <- merged:
+		case <-w.exit:
+			return
+		}
+	}
+}
+
+func (w *mergedWatcher) Next() (*source.ChangeSet, error) {
+	select {
+	case <-w.exit:
+		return nil, errors.New("nacos config watcher has been stopped")
+	case cs := <-w.confChan:
+		return cs, nil
+	}
+}
+
+func (w *mergedWatcher) Stop() error {
+	select {
+	case <-w.exit:
+		return errors.New("nacos config watcher has been stopped")
+	default:
+		close(w.exit)
+		var errs []string
+		for _, watcher := range w.watchers {
+			if err := watcher.Stop(); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			return errors.New(strings.Join(errs, "; "))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/fault"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config"
+	"github.com/asim/go-micro/v3/config/reader"
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"testing"
+)
+
+func TestMergedRead(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "common.yaml", "redis:\n  addr: common:6379\n  db: 0\nlog: info\n")
+	publish(t, client, "redis.yaml", "redis:\n  addr: redis:6379\n")
+	publish(t, client, "gateway.json", `{"redis":{"db":3}}`)
+	sour := NewMergedSource(mockOptions(client,
+		nacos.ConfEntry(nacos.DataId("common.yaml"), nacos.Group("DEFAULT_GROUP")),
+		nacos.ConfEntry(nacos.DataId("redis.yaml"), nacos.Group("DEFAULT_GROUP")),
+		nacos.ConfEntry(nacos.DataId("missing.yaml"), nacos.Group("DEFAULT_GROUP"), nacos.Optional(true)),
+		nacos.ConfEntry(nacos.DataId("gateway.json"), nacos.Group("DEFAULT_GROUP")),
+	)...)
+	c, err := config.NewConfig(config.WithSource(sour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if addr := c.Get("redis", "addr").String(""); addr != "redis:6379" {
+		t.Fatalf("unexpected addr %q", addr)
+	}
+	if db := c.Get("redis", "db").Int(-1); db != 3 {
+		t.Fatalf("unexpected db %d", db)
+	}
+	if log := c.Get("log").String(""); log != "info" {
+		t.Fatalf("unexpected log %q", log)
+	}
+}
+
+func TestMergedRequired(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "common.yaml", "log: info")
+	sour := NewMergedSource(mockOptions(client,
+		nacos.ConfEntry(nacos.DataId("common.yaml"), nacos.Group("DEFAULT_GROUP")),
+		nacos.ConfEntry(nacos.DataId("missing.yaml"), nacos.Group("DEFAULT_GROUP")),
+	)...)
+	if _, err := sour.Read(); err == nil {
+		t.Fatal("expected error for missing required entry")
+	}
+}
+
+func TestMergedNamespace(t *testing.T) {
+	_, err := newMergedSource(
+		nacos.ConfClient(nacos.NamespaceId("mock")),
+		nacos.ConfigClient(mock.NewConfigClient()),
+		nacos.ConfEntry(nacos.DataId("common.yaml"), nacos.Group("DEFAULT_GROUP"), nacos.ConfNamespaceId("public")),
+	)
+	if err == nil {
+		t.Fatal("expected error for namespace without server options")
+	}
+}
+
+// changeSetValues 用本包的reader解析cs
+func changeSetValues(t *testing.T, cs *source.ChangeSet) reader.Values {
+	t.Helper()
+	r := NewReader()
+	merged, err := r.Merge(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := r.Values(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestMergedWatch(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "common.yaml", "a: 1\nb: 1\n")
+	publish(t, client, "gateway.yaml", "b: 2\n")
+	sour := NewMergedSource(mockOptions(client,
+		nacos.ConfEntry(nacos.DataId("common.yaml"), nacos.Group("DEFAULT_GROUP")),
+		nacos.ConfEntry(nacos.DataId("gateway.yaml"), nacos.Group("DEFAULT_GROUP")),
+	)...)
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	// 修改优先级低的项，优先级高的项中的值仍然生效
+	publish(t, client, "common.yaml", "a: 3\nb: 3\n")
+	values := changeSetValues(t, nextChangeSet(t, w))
+	if a, b := values.Get("a").Int(0), values.Get("b").Int(0); a != 3 || b != 2 {
+		t.Fatalf("unexpected a %d, b %d", a, b)
+	}
+	// 删除优先级高的项
+	if _, err := client.DeleteConfig(vo.ConfigParam{DataId: "gateway.yaml", Group: "DEFAULT_GROUP"}); err != nil {
+		t.Fatal(err)
+	}
+	if b := changeSetValues(t, nextChangeSet(t, w)).Get("b").Int(0); b != 3 {
+		t.Fatalf("unexpected b %d", b)
+	}
+}
+
+func TestMergedOptionalUnavailable(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "common.yaml", "log: info")
+	sour := NewMergedSource(
+		nacos.ConfClient(nacos.NamespaceId("mock")),
+		nacos.ConfigClient(fault.NewConfigClient(client, fault.ErrorRate(1), fault.Methods("GetConfig"))),
+		nacos.ConfEntry(nacos.DataId("common.yaml"), nacos.Group("DEFAULT_GROUP"), nacos.Optional(true)),
+	)
+	// nacos不可用时可选项也返回错误
+	if _, err := sour.Read(); err == nil {
+		t.Fatal("expected error when nacos is unavailable")
+	}
+}
+
+func TestMergedNamespaces(t *testing.T) {
+	common, dev := mock.NewConfigClient(), mock.NewConfigClient()
+	publish(t, common, "gateway.yaml", "redis:\n  addr: common:6379\n  db: 0\nlog: info\n")
+	publish(t, dev, "gateway.yaml", "redis:\n  addr: dev:6379\n")
+	sour := NewSource(
+		nacos.ConfClient(nacos.NamespaceId("mock")),
+		nacos.ConfigClient(mock.NewConfigClient()),
+		nacos.NamespaceConfigClient("common", common),
+		nacos.NamespaceConfigClient("dev", dev),
+		nacos.ConfNamespaces("common", "dev"),
+		nacos.ConfParam(nacos.DataId("gateway.yaml"), nacos.Group("DEFAULT_GROUP")),
+	)
+	cs, err := sour.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := changeSetValues(t, cs)
+	if addr, log := values.Get("redis", "addr").String(""), values.Get("log").String(""); addr != "dev:6379" || log != "info" {
+		t.Fatalf("unexpected addr %q, log %q", addr, log)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	publish(t, common, "gateway.yaml", "redis:\n  addr: common:6379\n  db: 1\nlog: debug\n")
+	values = changeSetValues(t, nextChangeSet(t, w))
+	if addr, log := values.Get("redis", "addr").String(""), values.Get("log").String(""); addr != "dev:6379" || log != "debug" {
+		t.Fatalf("unexpected addr %q, log %q", addr, log)
+	}
+}
+
+func TestExpandNamespaces(t *testing.T) {
+	params := expandNamespaces([][]nacos.ConfigOption{
+		{nacos.DataId("common.yaml")},
+		{nacos.DataId("dev.yaml"), nacos.ConfNamespaceId("dev")},
+		{nacos.DataId("public.yaml"), nacos.ConfNamespaceId("public")},
+		{nacos.DataId("gateway.yaml")},
+	}, []string{"common", "dev"})
+	expected := []string{"common/common.yaml", "public/public.yaml", "common/gateway.yaml", "dev/common.yaml", "dev/dev.yaml", "dev/gateway.yaml"}
+	if len(params) != len(expected) {
+		t.Fatalf("unexpected params %v", params)
+	}
+	for i, param := range params {
+		if name := param.NamespaceId + "/" + param.DataId; name != expected[i] {
+			t.Errorf("unexpected param %d: %s", i, name)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/config/encoder/properties"
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/asim/go-micro/v3/logger"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultEnvPrefix = "APP_"
+
+// 本地文件的检查间隔
+var localInterval = time.Second
+
+// 各层的名称，文件层为文件路径
+const (
+	LayerNacos = "nacos"
+	// 环境变量层的名称为"env:"加变量名
+	LayerEnv = "env"
+)
+
+// overlaySource 在base之上依次叠加本地文件与环境变量
+type overlaySource struct {
+	base   source.Source
+	path   string
+	prefix string
+	mu     sync.Mutex
+	// base最近一次的内容
+	last *source.ChangeSet
+	// 最近一次合并结果中每个键的来源
+	origins map[string]string
+}
+
+// NewOverlaySource 本地文件与环境变量覆盖base中的同名键，本地文件的修改会触发推送
+// 环境变量去掉前缀后以"_"分隔各级键，按不区分大小写的方式匹配已有的键，如APP_REDIS_ADDR覆盖redis.addr，
+// 键中的"_"写为"__"，如APP_DB__POOL_SIZE覆盖db_pool.size
+func NewOverlaySource(base source.Source, localOpts ...nacos.LocalOption) source.Source {
+	localOptions := nacos.LocalOptions{
+		EnvPrefix: defaultEnvPrefix,
+	}
+	for _, localOpt := range localOpts {
+		localOpt(&localOptions)
+	}
+	return &overlaySource{
+		base:   base,
+		path:   localOptions.Path,
+		prefix: localOptions.EnvPrefix,
+	}
+}
+
+// Origins 返回最近一次合并结果中每个键(以"."连接)的来源层，用于排查配置来自哪里
+func Origins(src source.Source) (map[string]string, error) {
+	o, ok := src.(*overlaySource)
+	if !ok {
+		return nil, errors.New("not a nacos overlay source")
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	origins := make(map[string]string, len(o.origins))
+	for k, v := range o.origins {
+		origins[k] = v
+	}
+	return origins, nil
+}
+
+func (o *overlaySource) Read() (*source.ChangeSet, error) {
+	cs, err := o.base.Read()
+	if err != nil {
+		return nil, err
+	}
+	o.mu.Lock()
+	o.last = cs
+	o.mu.Unlock()
+	return o.build()
+}
+
+// build 以base最近一次的内容重新合并各层
+func (o *overlaySource) build() (*source.ChangeSet, error) {
+	o.mu.Lock()
+	last := o.last
+	o.mu.Unlock()
+	data, err := decodeMap(last)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]interface{})
+	origins := make(map[string]string)
+	overlay(merged, data, "", LayerNacos, origins)
+	files, err := o.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		local, err := decodeMap(&source.ChangeSet{
+			Data:   content,
+			Format: resolveFormat("", file, string(content)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("local config %s: %v", file, err)
+		}
+		overlay(merged, local, "", file, origins)
+	}
+	o.overlayEnv(merged, origins)
+
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	cs := &source.ChangeSet{
+		Data:      b,
+		Format:    "json",
+		Source:    o.String(),
+		Timestamp: time.Now(),
+	}
+	if IsStale(last) {
+		cs.Source += StaleSuffix
+	}
+	cs.Checksum = cs.Sum()
+	o.mu.Lock()
+	o.origins = origins
+	o.mu.Unlock()
+	return cs, nil
+}
+
+// files 返回按顺序叠加的本地文件，目录下只包含可识别扩展名的文件
+func (o *overlaySource) files() ([]string, error) {
+	if o.path == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(o.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{o.path}, nil
+	}
+	infos, err := ioutil.ReadDir(o.path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if _, ok := extFormats[strings.ToLower(filepath.Ext(name))]; ok {
+			files = append(files, filepath.Join(o.path, name))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// fingerprint 本地文件的名称、大小与修改时间，变化时重新合并
+func (o *overlaySource) fingerprint() string {
+	files, _ := o.files()
+	var b strings.Builder
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// overlay 将src叠加到dst上，两边都是map时逐级合并，否则整体替换
+func overlay(dst, src map[string]interface{}, path, layer string, origins map[string]string) {
+	for key, value := range src {
+		p := joinPath(path, key)
+		if sm, ok := value.(map[string]interface{}); ok {
+			if dm, ok := dst[key].(map[string]interface{}); ok {
+				overlay(dm, sm, p, layer, origins)
+				continue
+			}
+		}
+		replace(dst, key, value, p, layer, origins)
+	}
+}
+
+func replace(dst map[string]interface{}, key string, value interface{}, path, layer string, origins map[string]string) {
+	for k := range origins {
+		if k == path || strings.HasPrefix(k, path+".") {
+			delete(origins, k)
+		}
+	}
+	dst[key] = value
+	record(value, path, layer, origins)
+}
+
+// record 记录value下每个叶子的来源，空map视为叶子
+func record(value interface{}, path, layer string, origins map[string]string) {
+	if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+		for k, v := range m {
+			record(v, joinPath(path, k), layer, origins)
+		}
+		return
+	}
+	origins[path] = layer
+}
+
+func (o *overlaySource) overlayEnv(merged map[string]interface{}, origins map[string]string) {
+	if o.prefix == "" {
+		return
+	}
+	envs := os.Environ()
+	sort.Strings(envs)
+	for _, env := range envs {
+		pair := strings.SplitN(env, "=", 2)
+		if len(pair) != 2 || !strings.HasPrefix(pair[0], o.prefix) {
+			continue
+		}
+		segments := envSegments(strings.TrimPrefix(pair[0], o.prefix))
+		if len(segments) == 0 {
+			continue
+		}
+		m, path := merged, ""
+		for _, seg := range segments[:len(segments)-1] {
+			key := matchKey(m, seg)
+			path = joinPath(path, key)
+			if _, ok := m[key]; ok {
+				if _, ok := m[key].(map[string]interface{}); !ok {
+					m = nil
+					break
+				}
+			} else {
+				replace(m, key, make(map[string]interface{}), path, LayerEnv+":"+pair[0], origins)
+			}
+			m = m[key].(map[string]interface{})
+		}
+		// 不使用map替换已有的标量，如APP_REDIS_ADDR_HOST不覆盖redis.addr
+		if m == nil {
+			logger.Logf(logger.WarnLevel, "nacos overlay env %s ignored, %s is not a map", pair[0], path)
+			continue
+		}
+		key := matchKey(m, segments[len(segments)-1])
+		replace(m, key, envValue(m[key], pair[1]), joinPath(path, key), LayerEnv+":"+pair[0], origins)
+	}
+}
+
+// envSegments 以"_"分隔各级键，"__"表示键中的"_"，如DB__POOL_SIZE对应db_pool.size
+func envSegments(name string) []string {
+	var segments []string
+	for _, seg := range strings.Split(strings.Replace(name, "__", "\x00", -1), "_") {
+		if seg = strings.Replace(seg, "\x00", "_", -1); seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// matchKey 返回m中与seg不区分大小写相同的键，没有时使用小写的seg
+func matchKey(m map[string]interface{}, seg string) string {
+	for key := range m {
+		if strings.EqualFold(key, seg) {
+			return key
+		}
+	}
+	return strings.ToLower(seg)
+}
+
+// envValue 已有的键为字符串时保持为字符串，为数值或布尔值时按对应类型解析，解析失败或不存在时
+// 只转换能够无损往返的数值与布尔值，如"007"、"1.10"保持为字符串
+func envValue(existing interface{}, value string) interface{} {
+	switch existing.(type) {
+	case string:
+		return value
+	case float64:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case bool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return properties.Convert(value)
+}
+
+func (o *overlaySource) Write(cs *source.ChangeSet) error {
+	return errors.New("nacos overlay config doesn't implement Write method")
+}
+
+func (o *overlaySource) Watch() (source.Watcher, error) {
+	base, err := o.base.Watch()
+	if err != nil {
+		return nil, err
+	}
+	w := &overlayWatcher{
+		src:      o,
+		base:     base,
+		confChan: make(chan *source.ChangeSet, 10),
+		exit:     make(chan struct{}),
+	}
+	go w.watchBase()
+	go w.watchLocal(o.fingerprint())
+	return w, nil
+}
+
+func (o *overlaySource) String() string {
+	return o.base.String() + " (local overlay)"
+}
+
+type overlayWatcher struct {
+	src  *overlaySource
+	base source.Watcher
+	// base的推送与本地文件的变化按顺序合并
+	mu       sync.Mutex
+	confChan chan *source.ChangeSet
+	exit     chan struct{}
+}
+
+func (w *overlayWatcher) watchBase() {
+	for {
+		cs, err := w.base.Next()
+		if err != nil {
+			return
+		}
+		w.src.mu.Lock()
+		w.src.last = cs
+		w.src.mu.Unlock()
+		w.rebuild()
+	}
+}
+
+func (w *overlayWatcher) watchLocal(fingerprint string) {
+	ticker := time.NewTicker(localInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.exit:
+			return
+		case <-ticker.C:
+		}
+		if f := w.src.fingerprint(); f != fingerprint {
+			fingerprint = f
+			w.rebuild()
+		}
+	}
+}
+
+// rebuild 合并失败(如本地文件格式错误)时继续使用之前的配置
+func (w *overlayWatcher) rebuild() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cs, err := w.src.build()
+	if err != nil {
+		logger.Logf(logger.ErrorLevel, "nacos overlay config %s failed, err:%v", w.src, err)
+		return
+	}
+	select {
+	case w.confChan <- cs:
+	case <-w.exit:
+	}
+}
+
+func (w *overlayWatcher) Next() (*source.ChangeSet, error) {
+	select {
+	case <-w.exit:
+		return nil, errors.New("nacos config watcher has been stopped")
+	case cs := <-w.confChan:
+		return cs, nil
+	}
+}
+
+func (w *overlayWatcher) Stop() error {
+	select {
+	case <-w.exit:
+		return errors.New("nacos config watcher has been stopped")
+	default:
+		close(w.exit)
+		return w.base.Stop()
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.yaml"), []byte("redis:\n  addr: local:6379\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"fileServer":{"addr":"local:8080"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("NACOS_TEST_FILESERVER_ADDR", "env:8080")
+	os.Setenv("NACOS_TEST_KAFKA_PARTITIONS", "3")
+	defer os.Unsetenv("NACOS_TEST_FILESERVER_ADDR")
+	defer os.Unsetenv("NACOS_TEST_KAFKA_PARTITIONS")
+	localInterval = 10 * time.Millisecond
+	defer func() { localInterval = time.Second }()
+
+	client := mock.NewConfigClient()
+	publish(t, client, "overlay.yaml", "redis:\n  addr: nacos:6379\n  db: 1\nfileServer:\n  addr: nacos:8080\nlog: info\n")
+	sour := NewOverlaySource(newMockSource(client, "overlay.yaml"), nacos.LocalPath(dir), nacos.LocalEnvPrefix("NACOS_TEST_"))
+	cs, err := sour.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := changeSetValues(t, cs)
+	if addr := values.Get("redis", "addr").String(""); addr != "local:6379" {
+		t.Errorf("unexpected redis.addr %q", addr)
+	}
+	if addr := values.Get("fileServer", "addr").String(""); addr != "env:8080" {
+		t.Errorf("unexpected fileServer.addr %q", addr)
+	}
+	if db, partitions := values.Get("redis", "db").Int(0), values.Get("kafka", "partitions").Int(0); db != 1 || partitions != 3 {
+		t.Errorf("unexpected redis.db %d, kafka.partitions %d", db, partitions)
+	}
+	origins, err := Origins(sour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOrigins := map[string]string{
+		"redis.addr":       filepath.Join(dir, "a.yaml"),
+		"redis.db":         LayerNacos,
+		"fileServer.addr":  "env:NACOS_TEST_FILESERVER_ADDR",
+		"kafka.partitions": "env:NACOS_TEST_KAFKA_PARTITIONS",
+		"log":              LayerNacos,
+	}
+	for path, origin := range expectedOrigins {
+		if origins[path] != origin {
+			t.Errorf("unexpected origin of %s: %q", path, origins[path])
+		}
+	}
+
+	// 本地文件与nacos的修改都会生效
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.yaml"), []byte("redis:\n  addr: changed:6379\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if addr := changeSetValues(t, nextChangeSet(t, w)).Get("redis", "addr").String(""); addr != "changed:6379" {
+		t.Fatalf("unexpected addr after local change %q", addr)
+	}
+	publish(t, client, "overlay.yaml", "redis:\n  addr: nacos:6379\n  db: 2\n")
+	values = changeSetValues(t, nextChangeSet(t, w))
+	if addr, db := values.Get("redis", "addr").String(""), values.Get("redis", "db").Int(0); addr != "changed:6379" || db != 2 {
+		t.Fatalf("unexpected addr %q, db %d after nacos change", addr, db)
+	}
+}
+
+func TestOverlayEnv(t *testing.T) {
+	envs := map[string]string{
+		"NACOS_TEST_DB_PASSWORD":   "123456",
+		"NACOS_TEST_DB_PORT":       "3307",
+		"NACOS_TEST_VERSION":       "1.10",
+		"NACOS_TEST_CODE":          "007",
+		"NACOS_TEST_DB__POOL_SIZE": "5",
+		"NACOS_TEST_LOG_LEVEL":     "debug",
+	}
+	for k, v := range envs {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	merged := map[string]interface{}{
+		"db":  map[string]interface{}{"password": "secret", "port": float64(3306)},
+		"log": "info",
+	}
+	o := &overlaySource{prefix: "NACOS_TEST_"}
+	o.overlayEnv(merged, make(map[string]string))
+	db := merged["db"].(map[string]interface{})
+	// 已有键保持原来的类型，新的键只转换能够无损往返的值
+	if db["password"] != "123456" || db["port"] != float64(3307) {
+		t.Errorf("unexpected db %v", db)
+	}
+	if merged["version"] != "1.10" || merged["code"] != "007" {
+		t.Errorf("unexpected version %v, code %v", merged["version"], merged["code"])
+	}
+	if pool, ok := merged["db_pool"].(map[string]interface{}); !ok || pool["size"] != int64(5) {
+		t.Errorf("unexpected db_pool %v", merged["db_pool"])
+	}
+	// 已有的标量不被替换为map
+	if merged["log"] != "info" {
+		t.Errorf("unexpected log %v", merged["log"])
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin/config/encoder/properties"
+	"github.com/DMwangnima/nacos-plugin/config/encoder/text"
+	"github.com/DMwangnima/nacos-plugin/config/encoder/toml"
+	"github.com/DMwangnima/nacos-plugin/config/encoder/xml"
+	"github.com/DMwangnima/nacos-plugin/config/encoder/yaml"
+	"github.com/asim/go-micro/v3/config/encoder"
+	"github.com/asim/go-micro/v3/config/encoder/json"
+	"github.com/asim/go-micro/v3/config/reader"
+	jsonReader "github.com/asim/go-micro/v3/config/reader/json"
+)
+
+// Encoders 返回nacosSource可能产生的所有格式的编解码
+func Encoders() []encoder.Encoder {
+	return []encoder.Encoder{
+		json.NewEncoder(),
+		yaml.NewEncoder(),
+		toml.NewEncoder(),
+		xml.NewEncoder(),
+		properties.NewEncoder(),
+		text.NewEncoder(),
+	}
+}
+
+func encoderOf(format string) (encoder.Encoder, bool) {
+	for _, e := range Encoders() {
+		if e.String() == format {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// mapStrings 以fn的结果替换解码后配置中的字符串值，不替换key
+func mapStrings(v interface{}, fn func(string) (interface{}, error)) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		return fn(t)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			ev, err := mapStrings(e, fn)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = ev
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, e := range t {
+			ev, err := mapStrings(e, fn)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = ev
+		}
+		return s, nil
+	default:
+		return v, nil
+	}
+}
+
+// NewReader 注册了Encoders的go-micro json reader，通过config.WithReader使用
+func NewReader(opts ...reader.Option) reader.Reader {
+	options := make([]reader.Option, 0, len(opts)+6)
+	for _, e := range Encoders() {
+		options = append(options, reader.WithEncoder(e))
+	}
+	return jsonReader.NewReader(append(options, opts...)...)
+}
@@ -0,0 +1,264 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// schema JSON Schema的一个子集，支持type、enum、const、properties、required、additionalProperties、
+// items、minItems、maxItems、minimum、maximum、exclusiveMinimum、exclusiveMaximum、minLength、maxLength与pattern
+type schema struct {
+	Types                []string
+	Enum                 []interface{}
+	Const                interface{}
+	HasConst             bool
+	Properties           map[string]*schema
+	Required             []string
+	AdditionalProperties *schema
+	NoAdditional         bool
+	Items                *schema
+	MinItems             *int
+	MaxItems             *int
+	Minimum              *float64
+	Maximum              *float64
+	ExclusiveMinimum     *float64
+	ExclusiveMaximum     *float64
+	MinLength            *int
+	MaxLength            *int
+	Pattern              *regexp.Regexp
+}
+
+type rawSchema struct {
+	Type                 json.RawMessage            `json:"type"`
+	Enum                 []interface{}              `json:"enum"`
+	Const                json.RawMessage            `json:"const"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+	ExclusiveMinimum     *float64                   `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64                   `json:"exclusiveMaximum"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              *string                    `json:"pattern"`
+}
+
+func parseSchema(data []byte) (*schema, error) {
+	var raw rawSchema
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	s := &schema{
+		Enum:             raw.Enum,
+		Required:         raw.Required,
+		MinItems:         raw.MinItems,
+		MaxItems:         raw.MaxItems,
+		Minimum:          raw.Minimum,
+		Maximum:          raw.Maximum,
+		ExclusiveMinimum: raw.ExclusiveMinimum,
+		ExclusiveMaximum: raw.ExclusiveMaximum,
+		MinLength:        raw.MinLength,
+		MaxLength:        raw.MaxLength,
+	}
+	if len(raw.Type) > 0 {
+		var t string
+		if err := json.Unmarshal(raw.Type, &t); err == nil {
+			s.Types = []string{t}
+		} else if err := json.Unmarshal(raw.Type, &s.Types); err != nil {
+			return nil, fmt.Errorf("invalid schema type: %s", raw.Type)
+		}
+	}
+	if len(raw.Const) > 0 {
+		s.HasConst = true
+		if err := json.Unmarshal(raw.Const, &s.Const); err != nil {
+			return nil, err
+		}
+	}
+	if len(raw.Properties) > 0 {
+		s.Properties = make(map[string]*schema, len(raw.Properties))
+		for name, prop := range raw.Properties {
+			child, err := parseSchema(prop)
+			if err != nil {
+				return nil, err
+			}
+			s.Properties[name] = child
+		}
+	}
+	if len(raw.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(raw.AdditionalProperties, &allowed); err == nil {
+			s.NoAdditional = !allowed
+		} else {
+			child, err := parseSchema(raw.AdditionalProperties)
+			if err != nil {
+				return nil, err
+			}
+			s.AdditionalProperties = child
+		}
+	}
+	if len(raw.Items) > 0 {
+		child, err := parseSchema(raw.Items)
+		if err != nil {
+			return nil, err
+		}
+		s.Items = child
+	}
+	if raw.Pattern != nil {
+		re, err := regexp.Compile(*raw.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema pattern: %v", err)
+		}
+		s.Pattern = re
+	}
+	return s, nil
+}
+
+func schemaError(path, format string, args ...interface{}) error {
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Errorf("schema: %s: %s", path, fmt.Sprintf(format, args...))
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func typeOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if t == math.Trunc(t) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func (s *schema) validate(path string, v interface{}) error {
+	if len(s.Types) > 0 {
+		actual := typeOf(v)
+		matched := false
+		for _, t := range s.Types {
+			if t == actual || (t == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return schemaError(path, "expected %v, got %s", s.Types, actual)
+		}
+	}
+	if len(s.Enum) > 0 {
+		matched := false
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return schemaError(path, "value %v is not one of %v", v, s.Enum)
+		}
+	}
+	if s.HasConst && !reflect.DeepEqual(s.Const, v) {
+		return schemaError(path, "value %v is not %v", v, s.Const)
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return s.validateObject(path, t)
+	case []interface{}:
+		if s.MinItems != nil && len(t) < *s.MinItems {
+			return schemaError(path, "expected at least %d items, got %d", *s.MinItems, len(t))
+		}
+		if s.MaxItems != nil && len(t) > *s.MaxItems {
+			return schemaError(path, "expected at most %d items, got %d", *s.MaxItems, len(t))
+		}
+		if s.Items != nil {
+			for i, item := range t {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case float64:
+		if s.Minimum != nil && t < *s.Minimum {
+			return schemaError(path, "%v is less than %v", t, *s.Minimum)
+		}
+		if s.Maximum != nil && t > *s.Maximum {
+			return schemaError(path, "%v is greater than %v", t, *s.Maximum)
+		}
+		if s.ExclusiveMinimum != nil && t <= *s.ExclusiveMinimum {
+			return schemaError(path, "%v is not greater than %v", t, *s.ExclusiveMinimum)
+		}
+		if s.ExclusiveMaximum != nil && t >= *s.ExclusiveMaximum {
+			return schemaError(path, "%v is not less than %v", t, *s.ExclusiveMaximum)
+		}
+	case string:
+		length := utf8.RuneCountInString(t)
+		if s.MinLength != nil && length < *s.MinLength {
+			return schemaError(path, "length %d is less than %d", length, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return schemaError(path, "length %d is greater than %d", length, *s.MaxLength)
+		}
+		if s.Pattern != nil && !s.Pattern.MatchString(t) {
+			return schemaError(path, "%q does not match %s", t, s.Pattern)
+		}
+	}
+	return nil
+}
+
+func (s *schema) validateObject(path string, m map[string]interface{}) error {
+	for _, name := range s.Required {
+		if _, ok := m[name]; !ok {
+			return schemaError(path, "missing required property %s", name)
+		}
+	}
+	// 按key排序，保证错误信息稳定
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if prop, ok := s.Properties[k]; ok {
+			if err := prop.validate(join(path, k), m[k]); err != nil {
+				return err
+			}
+			continue
+		}
+		if s.NoAdditional {
+			return schemaError(path, "additional property %s is not allowed", k)
+		}
+		if s.AdditionalProperties != nil {
+			if err := s.AdditionalProperties.validate(join(path, k), m[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
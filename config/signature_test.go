@@ -0,0 +1,71 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/DMwangnima/nacos-plugin/signature"
+	"testing"
+)
+
+func TestSignatureTrailer(t *testing.T) {
+	key := signature.NewHMAC([]byte("release"))
+	client := mock.NewConfigClient()
+	sour := newMockSource(client, "signed.yaml", nacos.ConfSignature(nacos.SignatureVerifier(key)))
+	publish(t, client, "signed.yaml", "a: 1")
+	if _, err := sour.Read(); err != signature.ErrUnsigned {
+		t.Fatalf("expected unsigned error, got %v", err)
+	}
+	signed, err := signature.Sign(key, "a: 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, client, "signed.yaml", signed)
+	cs, err := sour.Read()
+	if err != nil || string(cs.Data) != "a: 1" {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	// 在控制台修改的内容签名无效
+	publish(t, client, "signed.yaml", "a: 2\n"+signed[len("a: 1\n"):])
+	signed, _ = signature.Sign(key, "a: 3")
+	publish(t, client, "signed.yaml", signed)
+	cs, err = w.Next()
+	if err != nil || string(cs.Data) != "a: 3" {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+}
+
+func TestSignatureCompanion(t *testing.T) {
+	key := signature.NewHMAC([]byte("release"))
+	client := mock.NewConfigClient()
+	sour := newMockSource(client, "companion.yaml", nacos.ConfSignature(nacos.SignatureVerifier(key), nacos.SignatureSuffix(".sig")))
+	publish(t, client, "companion.yaml", "a: 1")
+	sig, _ := signature.Detached(key, "a: 1")
+	publish(t, client, "companion.yaml.sig", sig)
+	cs, err := sour.Read()
+	if err != nil || string(cs.Data) != "a: 1" {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	// 先发布内容，签名更新后才交付
+	publish(t, client, "companion.yaml", "a: 2")
+	sig, _ = signature.Detached(key, "a: 2")
+	publish(t, client, "companion.yaml.sig", sig)
+	cs, err = w.Next()
+	if err != nil || string(cs.Data) != "a: 2" {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+	w.Stop()
+	if client.Listening("companion.yaml.sig", "DEFAULT_GROUP") {
+		t.Fatal("signature listener not cancelled")
+	}
+}
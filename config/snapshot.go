@@ -0,0 +1,95 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/DMwangnima/nacos-plugin/internal/snapfile"
+	"github.com/asim/go-micro/v3/config/source"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaleSuffix 从本地快照返回的ChangeSet的Source带有该后缀
+const StaleSuffix = " (stale)"
+
+var (
+	errSnapshotExpired  = errors.New("nacos config snapshot expired")
+	errSnapshotChecksum = errors.New("nacos config snapshot checksum mismatch")
+)
+
+// IsStale 返回cs是否来自本地快照
+func IsStale(cs *source.ChangeSet) bool {
+	return cs != nil && strings.HasSuffix(cs.Source, StaleSuffix)
+}
+
+type snapshotFile struct {
+	Timestamp time.Time `json:"timestamp"`
+	Checksum  string    `json:"checksum"`
+	Format    string    `json:"format"`
+	Data      string    `json:"data"`
+}
+
+// snapshot 将每个配置最近一次成功读取或收到推送的内容保存在磁盘上，一个配置一个文件
+type snapshot struct {
+	dir    string
+	maxAge time.Duration
+	mu     sync.Mutex
+	// 最近一次写入的checksum，内容无变化时不重复写盘
+	last map[string]string
+}
+
+func newSnapshot(dir string, maxAge time.Duration) *snapshot {
+	return &snapshot{
+		dir:    dir,
+		maxAge: maxAge,
+		last:   make(map[string]string),
+	}
+}
+
+func (s *snapshot) save(key string, cs *source.ChangeSet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last[key] == cs.Checksum {
+		return snapfile.Touch(s.dir, key)
+	}
+	data, err := json.Marshal(snapshotFile{
+		Timestamp: cs.Timestamp,
+		Checksum:  cs.Checksum,
+		Format:    cs.Format,
+		Data:      string(cs.Data),
+	})
+	if err != nil {
+		return err
+	}
+	if err := snapfile.Save(s.dir, key, data); err != nil {
+		return err
+	}
+	s.last[key] = cs.Checksum
+	return nil
+}
+
+func (s *snapshot) load(key string) (*source.ChangeSet, error) {
+	data, err := ioutil.ReadFile(snapfile.Path(s.dir, key))
+	if err != nil {
+		return nil, err
+	}
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if s.maxAge > 0 && time.Since(snapfile.VerifiedAt(s.dir, key, file.Timestamp)) > s.maxAge {
+		return nil, errSnapshotExpired
+	}
+	cs := &source.ChangeSet{
+		Data:      []byte(file.Data),
+		Format:    file.Format,
+		Timestamp: file.Timestamp,
+	}
+	if cs.Sum() != file.Checksum {
+		return nil, errSnapshotChecksum
+	}
+	cs.Checksum = file.Checksum
+	return cs, nil
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/fault"
+	"github.com/DMwangnima/nacos-plugin/internal/snapfile"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config/source"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ageSnapshot 将sour快照的写入时间与确认时间提前d
+func ageSnapshot(t *testing.T, dir string, sour source.Source, d time.Duration) {
+	t.Helper()
+	path := snapfile.Path(dir, sour.(*nacosSource).snapshotKey())
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Timestamp = file.Timestamp.Add(-d)
+	if data, err = json.Marshal(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-d)
+	if err := os.Chtimes(strings.TrimSuffix(path, ".json")+".verified", past, past); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigSnapshotFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := mock.NewConfigClient()
+	publish(t, client, "snapshot.json", `{"a":1}`)
+	cs, err := newMockSource(client, "snapshot.json", nacos.ConfSnapshot(nacos.SnapshotDir(dir))).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsStale(cs) {
+		t.Fatal("unexpected stale changeset")
+	}
+
+	// nacos不可用时返回快照
+	sour := newMockSource(fault.NewConfigClient(client, fault.ErrorRate(1), fault.Methods("GetConfig")), "snapshot.json", nacos.ConfSnapshot(nacos.SnapshotDir(dir)))
+	stale, err := sour.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsStale(stale) || string(stale.Data) != `{"a":1}` || stale.Checksum != cs.Checksum {
+		t.Fatalf("unexpected changeset %+v", stale)
+	}
+
+	// 快照过期
+	sour = newMockSource(fault.NewConfigClient(client, fault.ErrorRate(1), fault.Methods("GetConfig")), "snapshot.json", nacos.ConfSnapshot(nacos.SnapshotDir(dir), nacos.SnapshotMaxAge(time.Nanosecond)))
+	if _, err := sour.Read(); err != fault.ErrInjected {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+}
+
+func TestConfigSnapshotCorrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := mock.NewConfigClient()
+	publish(t, client, "snapshot.json", `{"a":1}`)
+	if _, err := newMockSource(client, "snapshot.json", nacos.ConfSnapshot(nacos.SnapshotDir(dir))).Read(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("unexpected snapshot files %v, err: %v", files, err)
+	}
+	if err := ioutil.WriteFile(files[0], []byte(`{"checksum":"x","data":"{}"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sour := newMockSource(fault.NewConfigClient(client, fault.ErrorRate(1), fault.Methods("GetConfig")), "snapshot.json", nacos.ConfSnapshot(nacos.SnapshotDir(dir)))
+	if _, err := sour.Read(); err != fault.ErrInjected {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+}
+
+func TestConfigSnapshotResync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(interval time.Duration) { resyncInterval = interval }(resyncInterval)
+	resyncInterval = 10 * time.Millisecond
+
+	client := mock.NewConfigClient()
+	publish(t, client, "snapshot.json", `{"a":1}`)
+	if _, err := newMockSource(client, "snapshot.json", nacos.ConfSnapshot(nacos.SnapshotDir(dir))).Read(); err != nil {
+		t.Fatal(err)
+	}
+	// nacos在启动时不可用，之后恢复
+	sour := newMockSource(fault.NewConfigClient(client, fault.ErrorRate(1), fault.MaxFaults(3), fault.Methods("GetConfig")), "snapshot.json", nacos.ConfSnapshot(nacos.SnapshotDir(dir)))
+	cs, err := sour.Read()
+	if err != nil || !IsStale(cs) {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	// 恢复后即使内容没有变化也会拉取一次
+	cs, err = w.Next()
+	if err != nil || IsStale(cs) || string(cs.Data) != `{"a":1}` {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+}
+
+func TestConfigSnapshotMaxAgeSinceVerified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := mock.NewConfigClient()
+	publish(t, client, "snapshot.json", `{"a":1}`)
+	sour := newMockSource(client, "snapshot.json", nacos.ConfSnapshot(nacos.SnapshotDir(dir)))
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	ageSnapshot(t, dir, sour, time.Hour)
+	// 内容没有变化，但再次读取成功后快照重新计算有效期
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	sour = newMockSource(fault.NewConfigClient(client, fault.ErrorRate(1), fault.Methods("GetConfig")), "snapshot.json", nacos.ConfSnapshot(nacos.SnapshotDir(dir), nacos.SnapshotMaxAge(time.Minute)))
+	if _, err := sour.Read(); err != nil {
+		t.Fatalf("expected snapshot verified recently, err: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/asim/go-micro/v3/config/source"
+)
+
+// ParseValidator 检查配置能否按其格式解析，空配置视为合法
+func ParseValidator() nacos.ConfigValidator {
+	return func(cs *source.ChangeSet) error {
+		if len(cs.Data) == 0 {
+			return nil
+		}
+		_, err := decodeChangeSet(cs)
+		return err
+	}
+}
+
+// SchemaValidator 按JSON Schema检查解析后的配置，支持的关键字见schema.go
+func SchemaValidator(schema []byte) (nacos.ConfigValidator, error) {
+	s, err := parseSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	return func(cs *source.ChangeSet) error {
+		data, err := decodeChangeSet(cs)
+		if err != nil {
+			return err
+		}
+		return s.validate("", data)
+	}, nil
+}
+
+// decodeChangeSet 按cs的格式解析，并经过json转换为通用的类型
+func decodeChangeSet(cs *source.ChangeSet) (interface{}, error) {
+	format := normalizeFormat(cs.Format)
+	e, ok := encoderOf(format)
+	if !ok {
+		return nil, fmt.Errorf("unsupported config format %s", format)
+	}
+	var m map[string]interface{}
+	if err := e.Decode(cs.Data, &m); err != nil {
+		return nil, fmt.Errorf("invalid %s config: %v", format, err)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
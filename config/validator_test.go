@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/metrics"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config/source"
+	"strings"
+	"testing"
+)
+
+func TestValidatePush(t *testing.T) {
+	client := mock.NewConfigClient()
+	m := metrics.NewMemory()
+	publish(t, client, "validate.yaml", "a: 1")
+	sour := newMockSource(client, "validate.yaml",
+		nacos.ConfMetrics(m),
+		nacos.ConfValidators(ParseValidator()),
+	)
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	// 不合法的yaml被拒绝
+	publish(t, client, "validate.yaml", "a: [1")
+	publish(t, client, "validate.yaml", "a: 2")
+	cs, err := w.Next()
+	if err != nil || string(cs.Data) != "a: 2" {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+	if v := m.CounterValue(metrics.ConfigRejected, metrics.Labels{"data_id": "validate.yaml", "group": "DEFAULT_GROUP"}); v != 1 {
+		t.Fatalf("expected 1 rejected push, got %v", v)
+	}
+}
+
+func TestValidateRead(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "validate.json", `{"a":`)
+	rejected := errors.New("rejected")
+	sour := newMockSource(client, "validate.json",
+		nacos.ConfValidators(ParseValidator(), func(cs *source.ChangeSet) error {
+			return rejected
+		}),
+	)
+	if _, err := sour.Read(); err == nil || !strings.Contains(err.Error(), "invalid json config") {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+	publish(t, client, "validate.json", `{"a":1}`)
+	if _, err := sour.Read(); err != rejected {
+		t.Fatalf("expected custom validator error, got %v", err)
+	}
+}
+
+func TestSchemaValidator(t *testing.T) {
+	validator, err := SchemaValidator([]byte(`{
+		"type": "object",
+		"required": ["redis"],
+		"properties": {
+			"redis": {
+				"type": "object",
+				"required": ["addr"],
+				"additionalProperties": false,
+				"properties": {
+					"addr": {"type": "string", "pattern": "^[^:]+:[0-9]+$"},
+					"db": {"type": "integer", "minimum": 0, "maximum": 15}
+				}
+			},
+			"level": {"enum": ["debug", "info"]},
+			"hosts": {"type": "array", "minItems": 1, "items": {"type": "string"}}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"redis:\n  addr: 127.0.0.1:6379\n  db: 1\nhosts: [a]\n": "",
+		"level: info\n":                        "missing required property redis",
+		"redis:\n  addr: 127.0.0.1\n":          "redis.addr",
+		"redis:\n  addr: a:1\n  db: 16\n":      "redis.db: 16 is greater than 15",
+		"redis:\n  addr: a:1\n  db: 1.5\n":     "redis.db: expected [integer]",
+		"redis:\n  addr: a:1\n  password: x\n": "additional property password",
+		"redis:\n  addr: a:1\nlevel: warn\n":   "level: value warn",
+		"redis:\n  addr: a:1\nhosts: []\n":     "hosts: expected at least 1 items",
+		"redis:\n  addr: a:1\nhosts: [1]\n":    "hosts[0]: expected [string]",
+	}
+	for content, expected := range cases {
+		err := validator(&source.ChangeSet{Data: []byte(content), Format: "yaml"})
+		if expected == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", content, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected error containing %q, got %v", content, expected, err)
+		}
+	}
+}
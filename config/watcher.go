@@ -1,67 +1,209 @@
 package config
 
 import (
+	"context"
 	"errors"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/metrics"
 	"github.com/asim/go-micro/v3/config/source"
 	"github.com/asim/go-micro/v3/logger"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"sync"
 	"time"
 )
 
+// 从快照启动后重新拉取配置的间隔
+var resyncInterval = 5 * time.Second
+
+// nacosWatcher 只保留最新一次尚未被Next取走的配置，回调不会阻塞sdk的监听协程
 type nacosWatcher struct {
-	src      *nacosSource
-	confChan chan *source.ChangeSet
-	exit     chan struct{}
+	src *nacosSource
+	mu  sync.Mutex
+	// 尚未被Next取走的最新配置，新的推送直接覆盖
+	pending *source.ChangeSet
+	// 最近一次交付的checksum，内容相同的推送被丢弃
+	last   string
+	notify chan struct{}
+	exit   chan struct{}
+	stop   sync.Once
 }
 
 func newNacosWatcher(n *nacosSource) (source.Watcher, error) {
 	watcher := &nacosWatcher{
-		src:      n,
-		confChan: make(chan *source.ChangeSet, 10),
-		exit:     make(chan struct{}),
+		src:    n,
+		last:   n.lastChecksum(),
+		notify: make(chan struct{}, 1),
+		exit:   make(chan struct{}),
 	}
-	if err := watcher.subscribe(); err != nil {
+	if err := n.subscribe(watcher); err != nil {
 		logger.Logf(logger.ErrorLevel, "nacos subscribe config failed, err:%v", err)
 		return nil, err
 	}
 	return watcher, nil
 }
 
-func (n *nacosWatcher) subscribe() error {
-	n.src.param.OnChange = n.watcherCallback
-	return n.src.config.ListenConfig(n.src.param.ConfigParam)
+// subscribe 每个配置源只在nacos注册一个监听，第一个watcher注册时开始监听
+func (n *nacosSource) subscribe(w *nacosWatcher) error {
+	n.listenMu.Lock()
+	defer n.listenMu.Unlock()
+	if len(n.watchers) > 0 {
+		n.watchers[w] = struct{}{}
+		return nil
+	}
+	if err := n.config.ListenConfig(n.listenParam()); err != nil {
+		return err
+	}
+	// 伴随DataId中的签名更新后重新校验配置
+	if n.signature != nil && n.signature.Suffix != "" {
+		param := n.signatureParam()
+		param.OnChange = n.signatureCallback
+		if err := n.config.ListenConfig(param); err != nil {
+			n.config.CancelListenConfig(n.listenParam())
+			return err
+		}
+	}
+	n.watchers = map[*nacosWatcher]struct{}{w: {}}
+	n.stopResync = make(chan struct{})
+	if n.isStale() {
+		go n.resync(n.stopResync)
+	}
+	return nil
 }
 
-func (n *nacosWatcher) watcherCallback(namespace, group, dataId, data string) {
-	select {
-	case <-n.exit:
+// unsubscribe 最后一个watcher停止时取消监听
+func (n *nacosSource) unsubscribe(w *nacosWatcher) error {
+	n.listenMu.Lock()
+	defer n.listenMu.Unlock()
+	if _, ok := n.watchers[w]; !ok {
+		return nil
+	}
+	delete(n.watchers, w)
+	if len(n.watchers) > 0 {
+		return nil
+	}
+	close(n.stopResync)
+	if n.signature != nil && n.signature.Suffix != "" {
+		if err := n.config.CancelListenConfig(n.signatureParam()); err != nil {
+			logger.Logf(logger.WarnLevel, "nacos cancel listening signature of %s failed, err:%v", n, err)
+		}
+	}
+	return n.config.CancelListenConfig(n.listenParam())
+}
+
+// listenParam 监听使用的ConfigParam，不修改Read等共用的n.param
+func (n *nacosSource) listenParam() vo.ConfigParam {
+	param := n.param.ConfigParam
+	param.OnChange = n.watcherCallback
+	return param
+}
+
+func (n *nacosSource) signatureCallback(namespace, group, dataId, data string) {
+	content, err := n.config.GetConfig(n.param.ConfigParam)
+	if err != nil {
+		logger.Logf(logger.WarnLevel, "nacos getconfig %s after signature changed failed, err:%v", n, err)
 		return
+	}
+	n.watcherCallback(namespace, group, n.param.DataId, content)
+}
+
+// watcherCallback 处理一次推送并分发给所有watcher
+func (n *nacosSource) watcherCallback(namespace, group, dataId, data string) {
+	n.metrics.Counter(metrics.ConfigChanges, metrics.Labels{"data_id": dataId, "group": group}, 1)
+	n.setMd5(data)
+	// 处理失败的配置不交给go-micro config，继续使用之前的配置
+	newCs, body, err := n.process(dataId, data, false)
+	n.hooks.Start(context.Background(), hook.ConfigChange, map[string]string{"data_id": dataId, "group": group})(err)
+	if err != nil {
+		return
+	}
+	if data != "" {
+		n.setStale(false)
+		n.saveSnapshot(body)
+	}
+	n.pinMu.Lock()
+	defer n.pinMu.Unlock()
+	if n.history != nil {
+		if _, ok := n.history.pinned(n.snapshotKey()); ok {
+			logger.Logf(logger.WarnLevel, "nacos config %s is pinned, ignore push", n)
+			return
+		}
+	}
+	n.recordHistory(newCs, body)
+	n.broadcast(newCs)
+}
+
+// broadcast 将配置交付给所有watcher
+func (n *nacosSource) broadcast(cs *source.ChangeSet) {
+	n.listenMu.Lock()
+	defer n.listenMu.Unlock()
+	for w := range n.watchers {
+		w.deliver(cs)
+	}
+}
+
+func (n *nacosWatcher) deliver(cs *source.ChangeSet) {
+	n.mu.Lock()
+	if cs.Checksum == n.last {
+		// 内容回到了已交付的版本，之前未取走的配置也不再需要
+		n.pending = nil
+		n.mu.Unlock()
+		return
+	}
+	n.pending = cs
+	n.mu.Unlock()
+	select {
+	case n.notify <- struct{}{}:
 	default:
 	}
-	newCs := &source.ChangeSet{
-		Data:      []byte(data),
-		Format:    "yml",
-		Source:    dataId + " " + group,
-		Timestamp: time.Now(),
+}
+
+// resync 从快照启动时，sdk的监听只在服务端内容与本地缓存不同时回调，因此nacos恢复后主动拉取一次
+func (n *nacosSource) resync(stop chan struct{}) {
+	ticker := time.NewTicker(resyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		// 已经收到推送
+		if !n.isStale() {
+			return
+		}
+		content, err := n.config.GetConfig(n.param.ConfigParam)
+		if err != nil {
+			continue
+		}
+		logger.Logf(logger.InfoLevel, "nacos config %s resynced", n)
+		n.watcherCallback(n.client.NamespaceId, n.param.Group, n.param.DataId, content)
+		return
 	}
-	newCs.Checksum = newCs.Sum()
-	n.confChan <- newCs
 }
 
 func (n *nacosWatcher) Next() (*source.ChangeSet, error) {
-	select {
-	case <-n.exit:
-		return nil, errors.New("nacos config watcher has been stopped")
-	case cs := <-n.confChan:
-		return cs, nil
+	for {
+		n.mu.Lock()
+		if cs := n.pending; cs != nil {
+			n.pending = nil
+			n.last = cs.Checksum
+			n.mu.Unlock()
+			return cs, nil
+		}
+		n.mu.Unlock()
+		select {
+		case <-n.exit:
+			return nil, errors.New("nacos config watcher has been stopped")
+		case <-n.notify:
+		}
 	}
 }
 
 func (n *nacosWatcher) Stop() error {
-	select {
-	case <-n.exit:
-		return errors.New("nacos config watcher has been stopped")
-	default:
+	err := errors.New("nacos config watcher has been stopped")
+	n.stop.Do(func() {
 		close(n.exit)
-		return n.src.config.CancelListenConfig(n.src.param.ConfigParam)
-	}
+		err = n.src.unsubscribe(n)
+	})
+	return err
 }
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config/source"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchCoalesce(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "coalesce.json", `{"a":0}`)
+	sour := newMockSource(client, "coalesce.json")
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	// mock的回调在PublishConfig中同步执行，回调阻塞时publish不会返回
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= 100; i++ {
+			publish(t, client, "coalesce.json", fmt.Sprintf(`{"a":%d}`, i))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watcher callback blocked")
+	}
+	cs, err := w.Next()
+	if err != nil || string(cs.Data) != `{"a":100}` {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+}
+
+func TestWatchDedupe(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "dedupe.json", `{"a":0}`)
+	sour := newMockSource(client, "dedupe.json")
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	watcher := w.(*nacosWatcher)
+	// 与Read相同的内容不交付
+	watcher.src.watcherCallback("", "DEFAULT_GROUP", "dedupe.json", `{"a":0}`)
+	// 改变后又改回，未被取走的配置被丢弃
+	watcher.src.watcherCallback("", "DEFAULT_GROUP", "dedupe.json", `{"a":1}`)
+	watcher.src.watcherCallback("", "DEFAULT_GROUP", "dedupe.json", `{"a":0}`)
+	watcher.src.watcherCallback("", "DEFAULT_GROUP", "dedupe.json", `{"a":2}`)
+	cs, err := w.Next()
+	if err != nil || string(cs.Data) != `{"a":2}` {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+	watcher.src.watcherCallback("", "DEFAULT_GROUP", "dedupe.json", `{"a":2}`)
+	watcher.src.watcherCallback("", "DEFAULT_GROUP", "dedupe.json", `{"a":3}`)
+	cs, err = w.Next()
+	if err != nil || string(cs.Data) != `{"a":3}` {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+}
+
+func TestWatchFanOut(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "fanout.json", `{"a":0}`)
+	sour := newMockSource(client, "fanout.json")
+	w1, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, client, "fanout.json", `{"a":1}`)
+	for _, w := range []source.Watcher{w1, w2} {
+		cs, err := w.Next()
+		if err != nil || string(cs.Data) != `{"a":1}` {
+			t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+		}
+	}
+	// 第一个watcher停止后第二个仍然能收到推送
+	if err := w1.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !client.Listening("fanout.json", "DEFAULT_GROUP") {
+		t.Fatal("listener cancelled while another watcher is running")
+	}
+	publish(t, client, "fanout.json", `{"a":2}`)
+	cs, err := w2.Next()
+	if err != nil || string(cs.Data) != `{"a":2}` {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+	if err := w2.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if client.Listening("fanout.json", "DEFAULT_GROUP") {
+		t.Fatal("listener not cancelled after all watchers stopped")
+	}
+	// 重新监听
+	w3, err := sour.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w3.Stop()
+	publish(t, client, "fanout.json", `{"a":3}`)
+	cs, err = w3.Next()
+	if err != nil || string(cs.Data) != `{"a":3}` {
+		t.Fatalf("unexpected changeset %v, err: %v", cs, err)
+	}
+}
+
+func TestWatchConcurrent(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "concurrent.json", `{"a":0}`)
+	sour := newMockSource(client, "concurrent.json")
+	// 监听与读取同时进行，重复的Stop不会panic
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w, err := sour.Watch()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if _, err := sour.Read(); err != nil {
+				t.Error(err)
+			}
+			var stops sync.WaitGroup
+			for j := 0; j < 2; j++ {
+				stops.Add(1)
+				go func() {
+					defer stops.Done()
+					w.Stop()
+				}()
+			}
+			stops.Wait()
+		}()
+	}
+	wg.Wait()
+	if client.Listening("concurrent.json", "DEFAULT_GROUP") {
+		t.Fatal("listener not cancelled after all watchers stopped")
+	}
+}
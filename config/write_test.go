@@ -0,0 +1,130 @@
+package config
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/config/source"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"testing"
+)
+
+func getConfig(t *testing.T, client *mock.ConfigClient, dataId string) string {
+	t.Helper()
+	content, err := client.GetConfig(vo.ConfigParam{DataId: dataId, Group: "DEFAULT_GROUP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestWrite(t *testing.T) {
+	client := mock.NewConfigClient()
+	sour := newMockSource(client, "write.json")
+	if err := sour.Write(&source.ChangeSet{Data: []byte(`{"a":1}`), Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	if content := getConfig(t, client, "write.json"); content != `{"a":1}` {
+		t.Fatalf("unexpected content %q", content)
+	}
+}
+
+func TestWriteEncode(t *testing.T) {
+	client := mock.NewConfigClient()
+	sour := newMockSource(client, "write.yaml")
+	if err := sour.Write(&source.ChangeSet{Data: []byte(`{"a":{"b":1}}`), Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	if content := getConfig(t, client, "write.yaml"); content != "a:\n  b: 1\n" {
+		t.Fatalf("unexpected content %q", content)
+	}
+	sour = newMockSource(client, "write", mockParam("write", nacos.Format("properties")))
+	if err := sour.Write(&source.ChangeSet{Data: []byte(`{"a":{"b":1}}`), Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	if content := getConfig(t, client, "write"); content != "a.b=1\n" {
+		t.Fatalf("unexpected content %q", content)
+	}
+}
+
+func TestWriteCompareAndSwap(t *testing.T) {
+	client := mock.NewConfigClient()
+	sour := newMockSource(client, "cas.json", mockParam("cas.json", nacos.CompareAndSwap(true)))
+	// 配置不存在时可以直接写入
+	if err := sour.Write(&source.ChangeSet{Data: []byte(`{"a":1}`)}); err != nil {
+		t.Fatal(err)
+	}
+	// 自己写入的内容不视为冲突
+	if err := sour.Write(&source.ChangeSet{Data: []byte(`{"a":2}`)}); err != nil {
+		t.Fatal(err)
+	}
+	publish(t, client, "cas.json", `{"a":3}`)
+	if err := sour.Write(&source.ChangeSet{Data: []byte(`{"a":4}`)}); err != ErrConflict {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if err := Delete(sour); err != ErrConflict {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sour.Write(&source.ChangeSet{Data: []byte(`{"a":4}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if content := getConfig(t, client, "cas.json"); content != `{"a":4}` {
+		t.Fatalf("unexpected content %q", content)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	client := mock.NewConfigClient()
+	publish(t, client, "delete.json", `{"a":1}`)
+	sour := newMockSource(client, "delete.json", mockParam("delete.json", nacos.CompareAndSwap(true)))
+	if _, err := sour.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Delete(sour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetConfig(vo.ConfigParam{DataId: "delete.json", Group: "DEFAULT_GROUP"}); err != mock.ErrConfigNotFound {
+		t.Fatalf("expected config to be deleted, got %v", err)
+	}
+	if err := Delete(NewMergedSource(mockOptions(client, nacos.ConfEntry(nacos.DataId("a"), nacos.Group("g")))...)); err == nil {
+		t.Fatal("expected error for merged source")
+	}
+}
+
+func TestWriteTransformed(t *testing.T) {
+	client := mock.NewConfigClient()
+	a := testCipher(t)
+	password, err := a.Encrypt([]byte("p@ss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "password: ENC(" + string(password) + ")\nhost: ${NACOS_TEST_HOST:-localhost}\n"
+	publish(t, client, "db.yaml", content)
+	// ENC(...)解密与占位符替换后的内容不能写回
+	decrypted := newMockSource(client, "db.yaml", nacos.ConfDecrypter(a))
+	interpolated := newMockSource(client, "db.yaml",
+		nacos.ConfInterpolation(),
+	)
+	for _, sour := range []source.Source{decrypted, interpolated} {
+		if _, err := sour.Read(); err != nil {
+			t.Fatal(err)
+		}
+		if err := sour.Write(&source.ChangeSet{Data: []byte("password: p@ss\nhost: localhost\n"), Format: "yaml"}); err != ErrTransformed {
+			t.Fatalf("expected ErrTransformed, got %v", err)
+		}
+	}
+	if getConfig(t, client, "db.yaml") != content {
+		t.Fatal("transformed config written back")
+	}
+	// 读取的内容没有ENC(...)与占位符时可以写回
+	publish(t, client, "plain.yaml", "host: localhost\n")
+	plain := newMockSource(client, "plain.yaml", nacos.ConfDecrypter(testCipher(t)), nacos.ConfInterpolation())
+	if _, err := plain.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Write(&source.ChangeSet{Data: []byte("host: 127.0.0.1\n"), Format: "yaml"}); err != nil {
+		t.Fatal(err)
+	}
+}
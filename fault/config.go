@@ -0,0 +1,77 @@
+package fault
+
+import (
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// ConfigClient 为IConfigClient注入延迟、错误、超时以及丢弃配置推送
+type ConfigClient struct {
+	*injector
+	config config_client.IConfigClient
+}
+
+func NewConfigClient(config config_client.IConfigClient, opts ...Option) *ConfigClient {
+	return &ConfigClient{
+		injector: newInjector(opts...),
+		config:   config,
+	}
+}
+
+func (c *ConfigClient) GetConfig(param vo.ConfigParam) (string, error) {
+	if err := c.before("GetConfig"); err != nil {
+		return "", err
+	}
+	return c.config.GetConfig(param)
+}
+
+func (c *ConfigClient) PublishConfig(param vo.ConfigParam) (bool, error) {
+	if err := c.before("PublishConfig"); err != nil {
+		return false, err
+	}
+	return c.config.PublishConfig(param)
+}
+
+func (c *ConfigClient) DeleteConfig(param vo.ConfigParam) (bool, error) {
+	if err := c.before("DeleteConfig"); err != nil {
+		return false, err
+	}
+	return c.config.DeleteConfig(param)
+}
+
+func (c *ConfigClient) ListenConfig(param vo.ConfigParam) error {
+	if err := c.before("ListenConfig"); err != nil {
+		return err
+	}
+	if onChange := param.OnChange; onChange != nil {
+		param.OnChange = func(namespace, group, dataId, data string) {
+			if c.drop("OnChange") {
+				return
+			}
+			onChange(namespace, group, dataId, data)
+		}
+	}
+	return c.config.ListenConfig(param)
+}
+
+func (c *ConfigClient) CancelListenConfig(param vo.ConfigParam) error {
+	if err := c.before("CancelListenConfig"); err != nil {
+		return err
+	}
+	return c.config.CancelListenConfig(param)
+}
+
+func (c *ConfigClient) SearchConfig(param vo.SearchConfigParm) (*model.ConfigPage, error) {
+	if err := c.before("SearchConfig"); err != nil {
+		return nil, err
+	}
+	return c.config.SearchConfig(param)
+}
+
+func (c *ConfigClient) PublishAggr(param vo.ConfigParam) (bool, error) {
+	if err := c.before("PublishAggr"); err != nil {
+		return false, err
+	}
+	return c.config.PublishAggr(param)
+}
@@ -0,0 +1,91 @@
+// fault 为nacos的naming与config client提供故障注入的装饰器，用于混沌测试
+package fault
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// injector 根据配置与随机数决定每次调用的故障，naming与config的装饰器共用
+type injector struct {
+	options Options
+	mu      sync.Mutex
+	rand    *rand.Rand
+	faults  int
+	// 各方法被注入故障的次数
+	counts map[string]int
+}
+
+func newInjector(opts ...Option) *injector {
+	options := Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &injector{
+		options: options,
+		rand:    rand.New(rand.NewSource(options.Seed)),
+		counts:  make(map[string]int),
+	}
+}
+
+func (i *injector) enabled(method string) bool {
+	if len(i.options.Methods) == 0 {
+		return true
+	}
+	_, ok := i.options.Methods[method]
+	return ok
+}
+
+// hit 以rate的概率返回true并计入故障次数，调用方需持有锁
+func (i *injector) hit(method string, rate float64) bool {
+	if rate <= 0 || (i.options.MaxFaults > 0 && i.faults >= i.options.MaxFaults) {
+		return false
+	}
+	if i.rand.Float64() >= rate {
+		return false
+	}
+	i.faults++
+	i.counts[method]++
+	return true
+}
+
+// before 在调用真正的client前执行，返回非nil时不再调用client
+func (i *injector) before(method string) error {
+	if !i.enabled(method) {
+		return nil
+	}
+	i.mu.Lock()
+	delay := i.options.Latency
+	if i.options.Jitter > 0 {
+		delay += time.Duration(i.rand.Int63n(int64(i.options.Jitter)))
+	}
+	var err error
+	if i.hit(method, i.options.TimeoutRate) {
+		delay += i.options.Timeout
+		err = ErrTimeout
+	} else if i.hit(method, i.options.ErrorRate) {
+		err = ErrInjected
+	}
+	i.mu.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return err
+}
+
+// drop 决定是否丢弃一次订阅回调
+func (i *injector) drop(method string) bool {
+	if !i.enabled(method) {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.hit(method, i.options.DropRate)
+}
+
+func (i *injector) Faults(method string) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.counts[method]
+}
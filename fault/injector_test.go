@@ -0,0 +1,81 @@
+package fault
+
+import (
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"testing"
+	"time"
+)
+
+func TestSeedDeterministic(t *testing.T) {
+	run := func() []error {
+		c := NewConfigClient(mock.NewConfigClient(), Seed(42), ErrorRate(0.5))
+		errs := make([]error, 20)
+		for i := range errs {
+			_, errs[i] = c.GetConfig(vo.ConfigParam{DataId: "a", Group: "g"})
+		}
+		return errs
+	}
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("call %d differs between runs with the same seed", i)
+		}
+	}
+}
+
+func TestMaxFaultsAndMethods(t *testing.T) {
+	c := NewConfigClient(mock.NewConfigClient(), ErrorRate(1), MaxFaults(2), Methods("GetConfig"))
+	param := vo.ConfigParam{DataId: "a", Group: "g", Content: "v"}
+	if _, err := c.PublishConfig(param); err != nil {
+		t.Fatalf("publish should not be affected, err: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetConfig(param); err != ErrInjected {
+			t.Fatalf("expected injected error, got %v", err)
+		}
+	}
+	if content, err := c.GetConfig(param); err != nil || content != "v" {
+		t.Fatalf("expected content after max faults, got %q %v", content, err)
+	}
+	if c.Faults("GetConfig") != 2 {
+		t.Fatalf("expected 2 faults, got %d", c.Faults("GetConfig"))
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	c := NewNamingClient(mock.NewNamingClient(), Timeout(1, 20*time.Millisecond))
+	start := time.Now()
+	if _, err := c.GetService(vo.GetServiceParam{ServiceName: "a"}); err != ErrTimeout {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatal("timeout returned too early")
+	}
+}
+
+func TestDropCallback(t *testing.T) {
+	naming := mock.NewNamingClient()
+	c := NewNamingClient(naming, DropRate(1), MaxFaults(1))
+	calls := 0
+	param := &vo.SubscribeParam{
+		ServiceName:       "a",
+		SubscribeCallback: func(_ []model.SubscribeService, _ error) { calls++ },
+	}
+	// 订阅时的首次回调被丢弃
+	if err := c.Subscribe(param); err != nil {
+		t.Fatal(err)
+	}
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "a", Ip: "10.0.0.1", Port: 80})
+	if calls != 1 {
+		t.Fatalf("expected 1 callback, got %d", calls)
+	}
+	if err := c.Unsubscribe(param); err != nil {
+		t.Fatal(err)
+	}
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "a", Ip: "10.0.0.2", Port: 80})
+	if calls != 1 {
+		t.Fatalf("expected no callback after unsubscribe, got %d", calls)
+	}
+}
@@ -0,0 +1,113 @@
+package fault
+
+import (
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"sync"
+)
+
+// NamingClient 为INamingClient注入延迟、错误、超时以及丢弃订阅回调
+type NamingClient struct {
+	*injector
+	naming naming_client.INamingClient
+	mu     sync.Mutex
+	// sdk以回调函数的地址区分订阅者，退订时需传入订阅时使用的参数
+	params map[*vo.SubscribeParam]*vo.SubscribeParam
+}
+
+func NewNamingClient(naming naming_client.INamingClient, opts ...Option) *NamingClient {
+	return &NamingClient{
+		injector: newInjector(opts...),
+		naming:   naming,
+		params:   make(map[*vo.SubscribeParam]*vo.SubscribeParam),
+	}
+}
+
+func (c *NamingClient) RegisterInstance(param vo.RegisterInstanceParam) (bool, error) {
+	if err := c.before("RegisterInstance"); err != nil {
+		return false, err
+	}
+	return c.naming.RegisterInstance(param)
+}
+
+func (c *NamingClient) DeregisterInstance(param vo.DeregisterInstanceParam) (bool, error) {
+	if err := c.before("DeregisterInstance"); err != nil {
+		return false, err
+	}
+	return c.naming.DeregisterInstance(param)
+}
+
+func (c *NamingClient) GetService(param vo.GetServiceParam) (model.Service, error) {
+	if err := c.before("GetService"); err != nil {
+		return model.Service{}, err
+	}
+	return c.naming.GetService(param)
+}
+
+func (c *NamingClient) SelectAllInstances(param vo.SelectAllInstancesParam) ([]model.Instance, error) {
+	if err := c.before("SelectAllInstances"); err != nil {
+		return nil, err
+	}
+	return c.naming.SelectAllInstances(param)
+}
+
+func (c *NamingClient) SelectInstances(param vo.SelectInstancesParam) ([]model.Instance, error) {
+	if err := c.before("SelectInstances"); err != nil {
+		return nil, err
+	}
+	return c.naming.SelectInstances(param)
+}
+
+func (c *NamingClient) SelectOneHealthyInstance(param vo.SelectOneHealthInstanceParam) (*model.Instance, error) {
+	if err := c.before("SelectOneHealthyInstance"); err != nil {
+		return nil, err
+	}
+	return c.naming.SelectOneHealthyInstance(param)
+}
+
+func (c *NamingClient) Subscribe(param *vo.SubscribeParam) error {
+	if err := c.before("Subscribe"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	wrapped, ok := c.params[param]
+	if !ok {
+		wrapped = &vo.SubscribeParam{
+			ServiceName: param.ServiceName,
+			Clusters:    param.Clusters,
+			GroupName:   param.GroupName,
+		}
+		callback := param.SubscribeCallback
+		wrapped.SubscribeCallback = func(services []model.SubscribeService, err error) {
+			if c.drop("SubscribeCallback") {
+				return
+			}
+			callback(services, err)
+		}
+		c.params[param] = wrapped
+	}
+	c.mu.Unlock()
+	return c.naming.Subscribe(wrapped)
+}
+
+func (c *NamingClient) Unsubscribe(param *vo.SubscribeParam) error {
+	if err := c.before("Unsubscribe"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	wrapped, ok := c.params[param]
+	delete(c.params, param)
+	c.mu.Unlock()
+	if !ok {
+		wrapped = param
+	}
+	return c.naming.Unsubscribe(wrapped)
+}
+
+func (c *NamingClient) GetAllServicesInfo(param vo.GetAllServiceInfoParam) (model.ServiceList, error) {
+	if err := c.before("GetAllServicesInfo"); err != nil {
+		return model.ServiceList{}, err
+	}
+	return c.naming.GetAllServicesInfo(param)
+}
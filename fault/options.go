@@ -0,0 +1,83 @@
+package fault
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInjected 注入的调用失败
+	ErrInjected = errors.New("fault: injected error")
+	// ErrTimeout 注入的调用超时
+	ErrTimeout = errors.New("fault: injected timeout")
+)
+
+type Options struct {
+	// 随机数种子，相同的种子与调用顺序会产生相同的故障序列
+	Seed int64
+	// 每次调用前固定增加的延迟，以及在[0, Jitter)内的随机延迟
+	Latency time.Duration
+	Jitter  time.Duration
+	// 调用直接返回ErrInjected的概率
+	ErrorRate float64
+	// 调用在等待Timeout后返回ErrTimeout的概率
+	TimeoutRate float64
+	Timeout     time.Duration
+	// 订阅回调(服务订阅与配置监听)被丢弃的概率
+	DropRate float64
+	// 最多注入的故障次数，0表示不限制
+	MaxFaults int
+	// 只对这些方法注入故障，为空表示所有方法
+	Methods map[string]struct{}
+}
+
+type Option func(*Options)
+
+func Seed(seed int64) Option {
+	return func(o *Options) {
+		o.Seed = seed
+	}
+}
+
+func Latency(latency, jitter time.Duration) Option {
+	return func(o *Options) {
+		o.Latency = latency
+		o.Jitter = jitter
+	}
+}
+
+func ErrorRate(rate float64) Option {
+	return func(o *Options) {
+		o.ErrorRate = rate
+	}
+}
+
+func Timeout(rate float64, timeout time.Duration) Option {
+	return func(o *Options) {
+		o.TimeoutRate = rate
+		o.Timeout = timeout
+	}
+}
+
+func DropRate(rate float64) Option {
+	return func(o *Options) {
+		o.DropRate = rate
+	}
+}
+
+func MaxFaults(n int) Option {
+	return func(o *Options) {
+		o.MaxFaults = n
+	}
+}
+
+// Methods 方法名与INamingClient/IConfigClient中的方法名一致，如Subscribe、GetConfig
+// 订阅回调的丢弃对应方法名SubscribeCallback与OnChange
+func Methods(methods ...string) Option {
+	return func(o *Options) {
+		o.Methods = make(map[string]struct{})
+		for _, m := range methods {
+			o.Methods[m] = struct{}{}
+		}
+	}
+}
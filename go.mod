@@ -3,6 +3,11 @@ module github.com/DMwangnima/nacos-plugin
 go 1.14
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/asim/go-micro/v3 v3.5.0
+	github.com/ghodss/yaml v1.0.0
+	github.com/json-iterator/go v1.1.10 // indirect
 	github.com/nacos-group/nacos-sdk-go v1.0.7
+	golang.org/x/sync v0.0.0-20201207232520-09787c993a3a
+	golang.org/x/sys v0.0.0-20200615200032-f1bc736245b1 // indirect
 )
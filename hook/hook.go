@@ -0,0 +1,126 @@
+// hook 在registry、selector与config的调用前后执行自定义逻辑，如链路追踪
+package hook
+
+import (
+	"context"
+	"github.com/DMwangnima/nacos-plugin/metrics"
+	"time"
+)
+
+// 操作名称
+const (
+	RegistryRegister     = "registry.register"
+	RegistryDeregister   = "registry.deregister"
+	RegistryGetService   = "registry.get_service"
+	RegistryListServices = "registry.list_services"
+	// 订阅推送产生的watch事件
+	RegistryWatchEvent = "registry.watch_event"
+
+	SelectorSelect = "selector.select"
+	SelectorMark   = "selector.mark"
+
+	ConfigRead   = "config.read"
+	ConfigWrite  = "config.write"
+	ConfigDelete = "config.delete"
+	// 收到配置推送
+	ConfigChange = "config.change"
+	// 固定到本地历史中的版本与取消固定
+	ConfigPin   = "config.pin"
+	ConfigUnpin = "config.unpin"
+)
+
+type Operation struct {
+	Name   string
+	Params map[string]string
+	Start  time.Time
+	// 以下两项在After中可用
+	Duration time.Duration
+	Err      error
+	// 调用方传入的context，Before中可替换为新的context，供After使用(如保存span)
+	Context context.Context
+}
+
+type Hook interface {
+	Before(op *Operation)
+	After(op *Operation)
+}
+
+// Funcs 以函数实现Hook，为nil的函数不执行
+type Funcs struct {
+	BeforeFunc func(op *Operation)
+	AfterFunc  func(op *Operation)
+}
+
+func (f Funcs) Before(op *Operation) {
+	if f.BeforeFunc != nil {
+		f.BeforeFunc(op)
+	}
+}
+
+func (f Funcs) After(op *Operation) {
+	if f.AfterFunc != nil {
+		f.AfterFunc(op)
+	}
+}
+
+// Hooks 按顺序执行Before，按逆序执行After
+type Hooks []Hook
+
+func noop(error) {}
+
+// Start 执行所有Before，返回的函数在操作结束时调用，执行所有After
+func (h Hooks) Start(ctx context.Context, name string, params map[string]string) func(err error) {
+	_, finish := h.StartContext(ctx, name, params)
+	return finish
+}
+
+// StartContext 与Start相同，同时返回Before替换后的context，嵌套的调用使用该context以建立父子关系
+func (h Hooks) StartContext(ctx context.Context, name string, params map[string]string) (context.Context, func(err error)) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(h) == 0 {
+		return ctx, noop
+	}
+	op := &Operation{
+		Name:    name,
+		Params:  params,
+		Start:   time.Now(),
+		Context: ctx,
+	}
+	for _, hook := range h {
+		hook.Before(op)
+	}
+	return op.Context, func(err error) {
+		op.Duration = time.Since(op.Start)
+		op.Err = err
+		for i := len(h) - 1; i >= 0; i-- {
+			h[i].After(op)
+		}
+	}
+}
+
+// Observer 同时执行hook与记录请求的次数和耗时，Requests与Latency为指标名称
+type Observer struct {
+	Hooks    Hooks
+	Metrics  metrics.Metrics
+	Requests string
+	Latency  string
+}
+
+// Observe 执行hook的Before，返回的函数在调用结束时记录指标并执行hook的After
+func (o Observer) Observe(ctx context.Context, name, op string, params map[string]string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, finish := o.Hooks.StartContext(ctx, name, params)
+	return ctx, func(err error) {
+		if o.Metrics != nil {
+			metrics.Request(o.Metrics, o.Requests, o.Latency, op, start, err)
+		}
+		finish(err)
+	}
+}
+
+// Event 记录一次没有耗时的事件，如订阅推送
+func (h Hooks) Event(name string, params map[string]string) {
+	h.Start(context.Background(), name, params)(nil)
+}
@@ -0,0 +1,40 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHooksOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Hook {
+		return Funcs{
+			BeforeFunc: func(op *Operation) { calls = append(calls, "before "+name) },
+			AfterFunc:  func(op *Operation) { calls = append(calls, "after "+name) },
+		}
+	}
+	var got *Operation
+	hooks := Hooks{record("a"), record("b"), Funcs{AfterFunc: func(op *Operation) { got = op }}}
+	errFailed := errors.New("failed")
+	hooks.Start(context.Background(), ConfigRead, map[string]string{"data_id": "a"})(errFailed)
+
+	expected := []string{"before a", "before b", "after b", "after a"}
+	if len(calls) != len(expected) {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Fatalf("unexpected calls %v", calls)
+		}
+	}
+	if got.Name != ConfigRead || got.Err != errFailed || got.Params["data_id"] != "a" {
+		t.Fatalf("unexpected operation %+v", got)
+	}
+}
+
+func TestEmptyHooks(t *testing.T) {
+	var hooks Hooks
+	hooks.Start(nil, ConfigRead, nil)(nil)
+	hooks.Event(ConfigChange, nil)
+}
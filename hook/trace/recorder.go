@@ -0,0 +1,76 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RecordedSpan Recorder记录的span
+type RecordedSpan struct {
+	Name       string
+	Parent     *RecordedSpan
+	Attributes map[string]string
+	Err        error
+	StartTime  time.Time
+	EndTime    time.Time
+
+	recorder *Recorder
+}
+
+func (s *RecordedSpan) SetAttribute(key, value string) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+func (s *RecordedSpan) RecordError(err error) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.Err = err
+}
+
+func (s *RecordedSpan) End() {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.EndTime = time.Now()
+	s.recorder.ended = append(s.recorder.ended, s)
+}
+
+type recorderKey struct{}
+
+// Recorder 内存中的Tracer，记录所有已结束的span，用于测试
+type Recorder struct {
+	mu    sync.Mutex
+	ended []*RecordedSpan
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(recorderKey{}).(*RecordedSpan)
+	span := &RecordedSpan{
+		Name:       name,
+		Parent:     parent,
+		Attributes: make(map[string]string),
+		StartTime:  time.Now(),
+		recorder:   r,
+	}
+	return context.WithValue(ctx, recorderKey{}, span), span
+}
+
+// Spans 按结束顺序返回已结束的span
+func (r *Recorder) Spans() []*RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*RecordedSpan(nil), r.ended...)
+}
+
+// Reset 清空已记录的span
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ended = nil
+}
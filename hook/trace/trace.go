@@ -0,0 +1,51 @@
+// trace 将hook适配为OpenTelemetry风格的span，使用时为所用的tracer实现Tracer接口即可
+package trace
+
+import (
+	"context"
+	"github.com/DMwangnima/nacos-plugin/hook"
+)
+
+type Span interface {
+	SetAttribute(key, value string)
+	RecordError(err error)
+	End()
+}
+
+// Tracer 与OpenTelemetry的trace.Tracer类似，返回的context中需包含新的span，以便嵌套调用建立父子关系
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// spanKey 以hook区分，多个tracing hook时各自结束自己的span
+type spanKey struct {
+	hook *tracingHook
+}
+
+type tracingHook struct {
+	tracer Tracer
+}
+
+// NewHook 为每个操作创建一个span，操作的参数作为span的属性
+func NewHook(tracer Tracer) hook.Hook {
+	return &tracingHook{tracer: tracer}
+}
+
+func (t *tracingHook) Before(op *hook.Operation) {
+	ctx, span := t.tracer.Start(op.Context, op.Name)
+	for k, v := range op.Params {
+		span.SetAttribute(k, v)
+	}
+	op.Context = context.WithValue(ctx, spanKey{t}, span)
+}
+
+func (t *tracingHook) After(op *hook.Operation) {
+	span, ok := op.Context.Value(spanKey{t}).(Span)
+	if !ok {
+		return
+	}
+	if op.Err != nil {
+		span.RecordError(op.Err)
+	}
+	span.End()
+}
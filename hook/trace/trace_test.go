@@ -0,0 +1,43 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"testing"
+)
+
+func TestHook(t *testing.T) {
+	recorder := NewRecorder()
+	hooks := hook.Hooks{NewHook(recorder)}
+
+	ctx, parent := recorder.Start(context.Background(), "request")
+	errFailed := errors.New("failed")
+	hooks.Start(ctx, hook.RegistryGetService, map[string]string{"service": "a"})(errFailed)
+	parent.End()
+
+	spans := recorder.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != hook.RegistryGetService || span.Attributes["service"] != "a" || span.Err != errFailed {
+		t.Fatalf("unexpected span %+v", span)
+	}
+	if span.Parent != spans[1] {
+		t.Fatal("span should be a child of the caller's span")
+	}
+	if span.EndTime.Before(span.StartTime) {
+		t.Fatal("span ended before it started")
+	}
+}
+
+func TestMultipleHooks(t *testing.T) {
+	first, second := NewRecorder(), NewRecorder()
+	hooks := hook.Hooks{NewHook(first), NewHook(second)}
+	hooks.Start(context.Background(), hook.ConfigRead, nil)(nil)
+	// 每个hook结束自己创建的span
+	if len(first.Spans()) != 1 || len(second.Spans()) != 1 {
+		t.Fatalf("unexpected spans %d, %d", len(first.Spans()), len(second.Spans()))
+	}
+}
@@ -0,0 +1,59 @@
+// snapfile 服务发现与配置的本地快照共用的文件操作
+// 每个key在dir下对应key.json与key.verified两个文件，后者的修改时间为内容最近一次被确认的时间
+package snapfile
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Path key对应的快照文件
+func Path(dir, key string) string {
+	return filepath.Join(dir, url.PathEscape(key)+".json")
+}
+
+// verifiedPath 每次成功获取后更新该文件的修改时间，内容无变化时快照仍然视为最新
+func verifiedPath(dir, key string) string {
+	return filepath.Join(dir, url.PathEscape(key)+".verified")
+}
+
+// Save 写入key的快照并更新确认时间
+func Save(dir, key string, data []byte) error {
+	if err := WriteFile(Path(dir, key), data); err != nil {
+		return err
+	}
+	return Touch(dir, key)
+}
+
+// WriteFile 先写临时文件再重命名，避免进程退出时留下不完整的文件
+func WriteFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Touch 快照内容无变化时只更新确认时间
+func Touch(dir, key string) error {
+	path := verifiedPath(dir, key)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); !os.IsNotExist(err) {
+		return err
+	}
+	return ioutil.WriteFile(path, nil, 0644)
+}
+
+// VerifiedAt 返回快照内容最近一次被确认的时间，written为内容写入的时间
+func VerifiedAt(dir, key string, written time.Time) time.Time {
+	if info, err := os.Stat(verifiedPath(dir, key)); err == nil && info.ModTime().After(written) {
+		return info.ModTime()
+	}
+	return written
+}
@@ -0,0 +1,46 @@
+package snapfile
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSnapfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	written := time.Now().Add(-time.Hour)
+	// 没有确认时间时使用写入时间
+	if at := VerifiedAt(dir, "a/b", written); !at.Equal(written) {
+		t.Fatalf("unexpected verified time %v", at)
+	}
+	if err := Save(dir, "a/b", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(Path(dir, "a/b"))
+	if err != nil || string(data) != "data" {
+		t.Fatalf("unexpected snapshot %q, err: %v", data, err)
+	}
+	if _, err := os.Stat(Path(dir, "a/b") + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left, err: %v", err)
+	}
+	verified := VerifiedAt(dir, "a/b", written)
+	if !verified.After(written) {
+		t.Fatalf("unexpected verified time %v", verified)
+	}
+	// Touch只更新确认时间
+	past := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(verifiedPath(dir, "a/b"), past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := Touch(dir, "a/b"); err != nil {
+		t.Fatal(err)
+	}
+	if !VerifiedAt(dir, "a/b", written).After(past) {
+		t.Fatal("verified time not updated")
+	}
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory 将指标保存在内存中，用于测试与调试
+type Memory struct {
+	mu         sync.Mutex
+	counters   map[string]float64
+	gauges     map[string]float64
+	histograms map[string][]float64
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		counters:   make(map[string]float64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string][]float64),
+	}
+}
+
+// key 形如name{a=1,b=2}，标签按名称排序
+func key(name string, labels Labels) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (m *Memory) Counter(name string, labels Labels, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[key(name, labels)] += delta
+}
+
+func (m *Memory) Histogram(name string, labels Labels, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := key(name, labels)
+	m.histograms[k] = append(m.histograms[k], value)
+}
+
+func (m *Memory) Gauge(name string, labels Labels, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[key(name, labels)] = value
+}
+
+func (m *Memory) CounterValue(name string, labels Labels) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[key(name, labels)]
+}
+
+func (m *Memory) GaugeValue(name string, labels Labels) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gauges[key(name, labels)]
+}
+
+// HistogramValues 返回所有观测值的副本
+func (m *Memory) HistogramValues(name string, labels Labels) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]float64(nil), m.histograms[key(name, labels)]...)
+}
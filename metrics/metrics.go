@@ -0,0 +1,60 @@
+// metrics 定义registry、selector与config使用的指标接口，默认不做任何记录
+package metrics
+
+import (
+	"time"
+)
+
+// 指标名称，由适配器自行加上前缀
+const (
+	// 调用次数，标签op、status
+	RegistryRequests = "registry_requests_total"
+	// 调用耗时(秒)，标签op
+	RegistryLatency = "registry_request_duration_seconds"
+	// 服务的节点数，标签service
+	RegistryNodes = "registry_service_nodes"
+	// 订阅回调产生的事件数，标签service、action
+	RegistryWatchEvents = "registry_watch_events_total"
+	// watcher中尚未被Next取走的事件数
+	RegistryWatchQueue = "registry_watch_queue_depth"
+
+	SelectorRequests = "selector_requests_total"
+	SelectorLatency  = "selector_request_duration_seconds"
+
+	ConfigRequests = "config_requests_total"
+	ConfigLatency  = "config_request_duration_seconds"
+	// 收到的配置推送数，标签data_id、group
+	ConfigChanges = "config_changes_total"
+	// 未通过校验被拒绝的配置数，标签data_id、group
+	ConfigRejected = "config_rejected_total"
+)
+
+type Labels map[string]string
+
+type Metrics interface {
+	Counter(name string, labels Labels, delta float64)
+	Histogram(name string, labels Labels, value float64)
+	Gauge(name string, labels Labels, value float64)
+}
+
+type noop struct{}
+
+func (noop) Counter(string, Labels, float64) {}
+
+func (noop) Histogram(string, Labels, float64) {}
+
+func (noop) Gauge(string, Labels, float64) {}
+
+func Noop() Metrics {
+	return noop{}
+}
+
+// Request 记录一次调用的次数与耗时，requests与latency为对应组件的指标名
+func Request(m Metrics, requests, latency, op string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.Counter(requests, Labels{"op": op, "status": status}, 1)
+	m.Histogram(latency, Labels{"op": op}, time.Since(start).Seconds())
+}
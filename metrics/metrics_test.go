@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequest(t *testing.T) {
+	m := NewMemory()
+	Request(m, RegistryRequests, RegistryLatency, "register", time.Now(), nil)
+	Request(m, RegistryRequests, RegistryLatency, "register", time.Now(), errors.New("failed"))
+	if v := m.CounterValue(RegistryRequests, Labels{"op": "register", "status": "ok"}); v != 1 {
+		t.Fatalf("expected 1 ok request, got %v", v)
+	}
+	if v := m.CounterValue(RegistryRequests, Labels{"status": "error", "op": "register"}); v != 1 {
+		t.Fatalf("expected 1 failed request, got %v", v)
+	}
+	if v := m.HistogramValues(RegistryLatency, Labels{"op": "register"}); len(v) != 2 {
+		t.Fatalf("expected 2 observations, got %v", v)
+	}
+}
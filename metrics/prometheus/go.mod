@@ -0,0 +1,9 @@
+module github.com/DMwangnima/nacos-plugin/metrics/prometheus
+
+go 1.14
+
+require (
+	github.com/DMwangnima/nacos-plugin v0.0.0-20261019042927-f99c84f5a2a1
+	github.com/asim/go-micro/v3 v3.5.0
+	github.com/prometheus/client_golang v1.7.1
+)
@@ -0,0 +1,115 @@
+// prometheus 将metrics.Metrics适配到prometheus，作为独立的module，只使用registry等功能时不引入prometheus的依赖
+// go.mod依赖根module已发布的版本，同时修改两个module时在仓库根目录执行go work init . ./metrics/prometheus，go.work不提交
+package prometheus
+
+import (
+	"github.com/DMwangnima/nacos-plugin/metrics"
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"sort"
+	"sync"
+)
+
+type promMetrics struct {
+	namespace  string
+	registerer prometheus.Registerer
+	mu         sync.Mutex
+	counters   map[string]*prometheus.CounterVec
+	histograms map[string]*prometheus.HistogramVec
+	gauges     map[string]*prometheus.GaugeVec
+}
+
+// New 返回prometheus适配器，指标名为namespace_name，registerer为nil时使用prometheus.DefaultRegisterer
+// 每个指标的标签名以第一次记录时为准
+func New(namespace string, registerer prometheus.Registerer) metrics.Metrics {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	return &promMetrics{
+		namespace:  namespace,
+		registerer: registerer,
+		counters:   make(map[string]*prometheus.CounterVec),
+		histograms: make(map[string]*prometheus.HistogramVec),
+		gauges:     make(map[string]*prometheus.GaugeVec),
+	}
+}
+
+func labelNames(labels metrics.Labels) []string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// register 注册新的指标，已被注册过时使用已有的指标
+func (p *promMetrics) register(c prometheus.Collector) prometheus.Collector {
+	if err := p.registerer.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		logger.Logf(logger.ErrorLevel, "nacos metrics register failed, err:%v", err)
+	}
+	return c
+}
+
+func (p *promMetrics) Counter(name string, labels metrics.Labels, delta float64) {
+	p.mu.Lock()
+	vec, ok := p.counters[name]
+	if !ok {
+		vec = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: p.namespace,
+			Name:      name,
+			Help:      name,
+		}, labelNames(labels))
+		if existing, ok := p.register(vec).(*prometheus.CounterVec); ok {
+			vec = existing
+		}
+		p.counters[name] = vec
+	}
+	p.mu.Unlock()
+	if c, err := vec.GetMetricWith(prometheus.Labels(labels)); err == nil {
+		c.Add(delta)
+	}
+}
+
+func (p *promMetrics) Histogram(name string, labels metrics.Labels, value float64) {
+	p.mu.Lock()
+	vec, ok := p.histograms[name]
+	if !ok {
+		vec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: p.namespace,
+			Name:      name,
+			Help:      name,
+		}, labelNames(labels))
+		if existing, ok := p.register(vec).(*prometheus.HistogramVec); ok {
+			vec = existing
+		}
+		p.histograms[name] = vec
+	}
+	p.mu.Unlock()
+	if h, err := vec.GetMetricWith(prometheus.Labels(labels)); err == nil {
+		h.Observe(value)
+	}
+}
+
+func (p *promMetrics) Gauge(name string, labels metrics.Labels, value float64) {
+	p.mu.Lock()
+	vec, ok := p.gauges[name]
+	if !ok {
+		vec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: p.namespace,
+			Name:      name,
+			Help:      name,
+		}, labelNames(labels))
+		if existing, ok := p.register(vec).(*prometheus.GaugeVec); ok {
+			vec = existing
+		}
+		p.gauges[name] = vec
+	}
+	p.mu.Unlock()
+	if g, err := vec.GetMetricWith(prometheus.Labels(labels)); err == nil {
+		g.Set(value)
+	}
+}
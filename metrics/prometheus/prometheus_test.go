@@ -0,0 +1,48 @@
+package prometheus
+
+import (
+	"github.com/DMwangnima/nacos-plugin/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+)
+
+func TestAdapter(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New("nacos", reg)
+	m.Counter(metrics.ConfigChanges, metrics.Labels{"data_id": "a", "group": "g"}, 1)
+	m.Counter(metrics.ConfigChanges, metrics.Labels{"data_id": "a", "group": "g"}, 2)
+	m.Gauge(metrics.RegistryNodes, metrics.Labels{"service": "s"}, 3)
+	m.Histogram(metrics.RegistryLatency, metrics.Labels{"op": "register"}, 0.1)
+	// 标签不一致的记录被忽略
+	m.Counter(metrics.ConfigChanges, metrics.Labels{"data_id": "a"}, 1)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64)
+	for _, f := range families {
+		for _, metric := range f.GetMetric() {
+			switch {
+			case metric.Counter != nil:
+				values[f.GetName()] = metric.Counter.GetValue()
+			case metric.Gauge != nil:
+				values[f.GetName()] = metric.Gauge.GetValue()
+			case metric.Histogram != nil:
+				values[f.GetName()] = float64(metric.Histogram.GetSampleCount())
+			}
+		}
+	}
+	if values["nacos_config_changes_total"] != 3 {
+		t.Fatalf("unexpected counter %v", values)
+	}
+	if values["nacos_registry_service_nodes"] != 3 {
+		t.Fatalf("unexpected gauge %v", values)
+	}
+	if values["nacos_registry_request_duration_seconds"] != 1 {
+		t.Fatalf("unexpected histogram %v", values)
+	}
+
+	// 重复创建适配器时复用已注册的指标
+	New("nacos", reg).Counter(metrics.ConfigChanges, metrics.Labels{"data_id": "a", "group": "g"}, 1)
+}
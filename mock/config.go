@@ -0,0 +1,149 @@
+package mock
+
+import (
+	"errors"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/util"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"strings"
+	"sync"
+)
+
+// ErrConfigNotFound 与sdk在配置不存在时返回的错误信息一致
+var ErrConfigNotFound = errors.New("config not found")
+
+// ConfigClient 内存版的IConfigClient
+// 与sdk一致，每个dataId+group只保留第一个监听者；为了测试的确定性，回调在PublishConfig中同步执行
+type ConfigClient struct {
+	mu        sync.Mutex
+	configs   map[string]model.ConfigItem
+	listeners map[string]vo.Listener
+}
+
+func NewConfigClient() *ConfigClient {
+	return &ConfigClient{
+		configs:   make(map[string]model.ConfigItem),
+		listeners: make(map[string]vo.Listener),
+	}
+}
+
+func configKey(dataId, group string) string {
+	return dataId + constant.CONFIG_INFO_SPLITER + group
+}
+
+func checkParam(param vo.ConfigParam) error {
+	if param.DataId == "" {
+		return errors.New("mock: dataId can not be empty")
+	}
+	if param.Group == "" {
+		return errors.New("mock: group can not be empty")
+	}
+	return nil
+}
+
+func (c *ConfigClient) GetConfig(param vo.ConfigParam) (string, error) {
+	if err := checkParam(param); err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.configs[configKey(param.DataId, param.Group)]
+	if !ok {
+		return "", ErrConfigNotFound
+	}
+	return item.Content, nil
+}
+
+func (c *ConfigClient) PublishConfig(param vo.ConfigParam) (bool, error) {
+	if err := checkParam(param); err != nil {
+		return false, err
+	}
+	if param.Content == "" {
+		return false, errors.New("mock: content can not be empty")
+	}
+	key := configKey(param.DataId, param.Group)
+	c.mu.Lock()
+	old, ok := c.configs[key]
+	c.configs[key] = model.ConfigItem{
+		DataId:  param.DataId,
+		Group:   param.Group,
+		Content: param.Content,
+		Md5:     util.Md5(param.Content),
+	}
+	listener := c.listeners[key]
+	c.mu.Unlock()
+	if listener != nil && (!ok || old.Content != param.Content) {
+		listener("", param.Group, param.DataId, param.Content)
+	}
+	return true, nil
+}
+
+func (c *ConfigClient) DeleteConfig(param vo.ConfigParam) (bool, error) {
+	if err := checkParam(param); err != nil {
+		return false, err
+	}
+	key := configKey(param.DataId, param.Group)
+	c.mu.Lock()
+	_, ok := c.configs[key]
+	delete(c.configs, key)
+	listener := c.listeners[key]
+	c.mu.Unlock()
+	if listener != nil && ok {
+		listener("", param.Group, param.DataId, "")
+	}
+	return true, nil
+}
+
+func (c *ConfigClient) ListenConfig(param vo.ConfigParam) error {
+	if err := checkParam(param); err != nil {
+		return err
+	}
+	key := configKey(param.DataId, param.Group)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.listeners[key]; !ok {
+		c.listeners[key] = param.OnChange
+	}
+	return nil
+}
+
+func (c *ConfigClient) CancelListenConfig(param vo.ConfigParam) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.listeners, configKey(param.DataId, param.Group))
+	return nil
+}
+
+func (c *ConfigClient) SearchConfig(param vo.SearchConfigParm) (*model.ConfigPage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	items := make([]model.ConfigItem, 0)
+	for _, item := range c.configs {
+		if param.DataId != "" && !strings.Contains(item.DataId, param.DataId) {
+			continue
+		}
+		if param.Group != "" && item.Group != param.Group {
+			continue
+		}
+		items = append(items, item)
+	}
+	return &model.ConfigPage{
+		TotalCount:     len(items),
+		PageNumber:     1,
+		PagesAvailable: 1,
+		PageItems:      items,
+	}, nil
+}
+
+func (c *ConfigClient) PublishAggr(param vo.ConfigParam) (bool, error) {
+	return c.PublishConfig(param)
+}
+
+// Listening 返回dataId+group当前是否有监听者
+func (c *ConfigClient) Listening(dataId, group string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.listeners[configKey(dataId, group)]
+	return ok
+}
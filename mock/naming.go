@@ -0,0 +1,197 @@
+package mock
+
+import (
+	"errors"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type callback = func(services []model.SubscribeService, err error)
+
+// NamingClient 内存版的INamingClient，用于在没有nacos server的情况下测试
+// 行为尽量与nacos-sdk-go保持一致：Subscribe时立即回调一次当前状态，实例为空时回调error
+type NamingClient struct {
+	mu sync.Mutex
+	// key为group@@service，value为该服务的实例，以ip:port为key
+	services  map[string]map[string]model.Instance
+	callbacks map[string][]*callback
+}
+
+func NewNamingClient() *NamingClient {
+	return &NamingClient{
+		services:  make(map[string]map[string]model.Instance),
+		callbacks: make(map[string][]*callback),
+	}
+}
+
+func groupedName(service, group string) string {
+	if group == "" {
+		group = constant.DEFAULT_GROUP
+	}
+	return group + constant.SERVICE_INFO_SPLITER + service
+}
+
+func (c *NamingClient) RegisterInstance(param vo.RegisterInstanceParam) (bool, error) {
+	if param.ServiceName == "" || param.Ip == "" || param.Port == 0 {
+		return false, errors.New("mock: serviceName, ip and port are required")
+	}
+	key := groupedName(param.ServiceName, param.GroupName)
+	addr := param.Ip + ":" + strconv.Itoa(int(param.Port))
+	c.mu.Lock()
+	if c.services[key] == nil {
+		c.services[key] = make(map[string]model.Instance)
+	}
+	c.services[key][addr] = model.Instance{
+		Valid:       true,
+		InstanceId:  param.Ip + "#" + strconv.Itoa(int(param.Port)) + "#" + param.ClusterName + "#" + key,
+		Port:        param.Port,
+		Ip:          param.Ip,
+		Weight:      param.Weight,
+		Metadata:    param.Metadata,
+		ClusterName: param.ClusterName,
+		ServiceName: param.ServiceName,
+		Enable:      param.Enable,
+		Healthy:     param.Healthy,
+		Ephemeral:   param.Ephemeral,
+	}
+	c.mu.Unlock()
+	c.notify(key)
+	return true, nil
+}
+
+func (c *NamingClient) DeregisterInstance(param vo.DeregisterInstanceParam) (bool, error) {
+	key := groupedName(param.ServiceName, param.GroupName)
+	addr := param.Ip + ":" + strconv.Itoa(int(param.Port))
+	c.mu.Lock()
+	if _, ok := c.services[key][addr]; !ok {
+		c.mu.Unlock()
+		return false, errors.New("mock: instance not found")
+	}
+	delete(c.services[key], addr)
+	if len(c.services[key]) == 0 {
+		delete(c.services, key)
+	}
+	c.mu.Unlock()
+	c.notify(key)
+	return true, nil
+}
+
+func (c *NamingClient) GetService(param vo.GetServiceParam) (model.Service, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return model.Service{
+		Name:  param.ServiceName,
+		Hosts: c.hosts(groupedName(param.ServiceName, param.GroupName)),
+	}, nil
+}
+
+func (c *NamingClient) SelectAllInstances(param vo.SelectAllInstancesParam) ([]model.Instance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hosts(groupedName(param.ServiceName, param.GroupName)), nil
+}
+
+func (c *NamingClient) SelectInstances(param vo.SelectInstancesParam) ([]model.Instance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hosts := make([]model.Instance, 0)
+	for _, host := range c.hosts(groupedName(param.ServiceName, param.GroupName)) {
+		if host.Healthy == param.HealthyOnly {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+func (c *NamingClient) SelectOneHealthyInstance(param vo.SelectOneHealthInstanceParam) (*model.Instance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, host := range c.hosts(groupedName(param.ServiceName, param.GroupName)) {
+		if host.Healthy {
+			return &host, nil
+		}
+	}
+	return nil, errors.New("mock: healthy instance list is empty")
+}
+
+func (c *NamingClient) Subscribe(param *vo.SubscribeParam) error {
+	key := groupedName(param.ServiceName, param.GroupName)
+	c.mu.Lock()
+	c.callbacks[key] = append(c.callbacks[key], &param.SubscribeCallback)
+	c.mu.Unlock()
+	c.notify(key)
+	return nil
+}
+
+func (c *NamingClient) Unsubscribe(param *vo.SubscribeParam) error {
+	key := groupedName(param.ServiceName, param.GroupName)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	funcs := c.callbacks[key][:0]
+	for _, f := range c.callbacks[key] {
+		if f != &param.SubscribeCallback {
+			funcs = append(funcs, f)
+		}
+	}
+	c.callbacks[key] = funcs
+	return nil
+}
+
+func (c *NamingClient) GetAllServicesInfo(param vo.GetAllServiceInfoParam) (model.ServiceList, error) {
+	prefix := groupedName("", param.GroupName)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doms := make([]string, 0)
+	for key := range c.services {
+		if len(key) > len(prefix) && key[:len(prefix)] == prefix {
+			doms = append(doms, key[len(prefix):])
+		}
+	}
+	sort.Strings(doms)
+	return model.ServiceList{Count: int64(len(doms)), Doms: doms}, nil
+}
+
+// 调用方需持有锁
+func (c *NamingClient) hosts(key string) []model.Instance {
+	hosts := make([]model.Instance, 0, len(c.services[key]))
+	for _, host := range c.services[key] {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].InstanceId < hosts[j].InstanceId
+	})
+	return hosts
+}
+
+// 与sdk的SubscribeCallback.ServiceChanged保持一致
+func (c *NamingClient) notify(key string) {
+	c.mu.Lock()
+	hosts := c.hosts(key)
+	funcs := append([]*callback(nil), c.callbacks[key]...)
+	c.mu.Unlock()
+	for _, f := range funcs {
+		if len(hosts) == 0 {
+			(*f)(nil, errors.New("[client.Subscribe] subscribe failed,hosts is empty"))
+			continue
+		}
+		services := make([]model.SubscribeService, len(hosts))
+		for i, host := range hosts {
+			services[i] = model.SubscribeService{
+				ClusterName: host.ClusterName,
+				Enable:      host.Enable,
+				InstanceId:  host.InstanceId,
+				Ip:          host.Ip,
+				Metadata:    host.Metadata,
+				Port:        host.Port,
+				ServiceName: host.ServiceName,
+				Valid:       host.Valid,
+				Weight:      host.Weight,
+			}
+		}
+		(*f)(services, nil)
+	}
+}
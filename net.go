@@ -0,0 +1,35 @@
+package nacos
+
+import "net"
+
+// LocalIP 返回本机第一个已启用网卡上的非回环IPv4地址，没有时返回IPv6地址，均没有时为空
+// 只读取网卡信息，不依赖外部网络
+func LocalIP() string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	var v6 string
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !ipNet.IP.IsGlobalUnicast() {
+				continue
+			}
+			if ip := ipNet.IP.To4(); ip != nil {
+				return ip.String()
+			}
+			if v6 == "" {
+				v6 = ipNet.IP.String()
+			}
+		}
+	}
+	return v6
+}
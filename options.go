@@ -2,10 +2,18 @@ package nacos
 
 import (
 	"context"
+	"github.com/DMwangnima/nacos-plugin/cipher"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/metrics"
+	"github.com/DMwangnima/nacos-plugin/signature"
 	"github.com/asim/go-micro/v3/config/source"
 	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/selector"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
 	"github.com/nacos-group/nacos-sdk-go/common/constant"
 	"github.com/nacos-group/nacos-sdk-go/vo"
+	"time"
 )
 
 type ClientOptions struct {
@@ -22,6 +30,51 @@ type InstanceOptions struct {
 
 type ConfigOptions struct {
 	vo.ConfigParam
+	// 配置格式，如json、yaml、toml、xml、properties、text，为空时自动识别
+	Format string
+	// 写入与删除前检查服务端的MD5是否与最近一次读取的一致
+	CompareAndSwap bool
+	// 写入时以gzip压缩并base64编码，读取时自动识别
+	Compress bool
+	// 写入的内容超过该大小(字节)时拆分为dataId.part-N并以清单作为主DataId的内容，0表示不拆分
+	ChunkSize int
+	// 以下只用于合并配置源的项
+	// 配置所在的命名空间，为空时使用client的命名空间
+	NamespaceId string
+	// 配置不存在时是否忽略该项
+	Optional bool
+	// 同一组的项中至少要有一项存在，单独的项不存在时忽略
+	RequiredGroup string
+}
+
+// 服务发现与配置的磁盘快照配置
+type SnapshotOptions struct {
+	// 快照目录，默认在client的CacheDir下
+	Dir string
+	// 快照的最大有效期，超过后不再使用，0表示不限制
+	MaxAge time.Duration
+}
+
+// 配置的本地历史，用于回滚到之前交付过的版本
+type HistoryOptions struct {
+	// 每个配置保留的版本数，默认为10
+	Size int
+	// 历史目录，默认在client的CacheDir下
+	Dir string
+}
+
+// nacosRegistry内置的服务缓存配置
+type CacheOptions struct {
+	// 缓存过期时间，过期后读取仍返回旧数据，同时在后台刷新
+	TTL time.Duration
+}
+
+// 本地覆盖层，叠加在nacos配置之上，用于开发时覆盖部分键
+type LocalOptions struct {
+	// 本地文件或目录，目录下的文件按名称顺序叠加，不存在时忽略
+	Path string
+	// 以该前缀开头的环境变量叠加在最上层，默认为APP_，指定为空时不叠加环境变量
+	EnvPrefix string
 }
 
 type ClientOption func(*ClientOptions)
@@ -32,6 +85,53 @@ type InstanceOption func(*InstanceOptions)
 
 type ConfigOption func(*ConfigOptions)
 
+type SnapshotOption func(*SnapshotOptions)
+
+type HistoryOption func(*HistoryOptions)
+
+type LocalOption func(*LocalOptions)
+
+type CacheOption func(*CacheOptions)
+
+// Resolver 解析配置中以名称为前缀的占位符，如${secret:db_password}中的db_password
+type Resolver func(key string) (string, error)
+
+// 配置内容的占位符替换配置
+type InterpolationOptions struct {
+	// key为占位符的前缀名称
+	Resolvers map[string]Resolver
+}
+
+type InterpolationOption func(*InterpolationOptions)
+
+// 配置签名的校验配置
+type SignatureOptions struct {
+	Verifier signature.Verifier
+	// 签名所在的伴随DataId的后缀，如".sig"，内容末尾没有签名行时从伴随DataId读取签名
+	Suffix string
+}
+
+type SignatureOption func(*SignatureOptions)
+
+// 灰度配置，实例属性匹配某条规则且对应的灰度DataId存在时，使用灰度DataId替换基础DataId
+type GrayOptions struct {
+	// 当前实例的属性，如version、cluster、ip以及自定义标签，未指定ip时使用本机ip
+	Attributes map[string]string
+	// 按顺序匹配，使用第一条匹配的规则
+	Rules []GrayRule
+}
+
+// GrayRule Match中的每一项都需要匹配，值以","分隔多个候选，支持通配符与CIDR
+type GrayRule struct {
+	Variant string
+	Match   map[string]string
+}
+
+type GrayOption func(*GrayOptions)
+
+// ConfigValidator 检查读取或推送的配置，返回error时拒绝该配置
+type ConfigValidator func(cs *source.ChangeSet) error
+
 type ServerNode []ServerOption
 
 type ClientKey struct{}
@@ -42,6 +142,36 @@ type InstanceKey struct{}
 
 type ConfParamKey struct{}
 
+type NamingClientKey struct{}
+
+type ConfigClientKey struct{}
+
+type NamespaceClientKey struct{}
+
+type NamespacesKey struct{}
+
+type SnapshotKey struct{}
+
+type CacheKey struct{}
+
+type MetricsKey struct{}
+
+type HookKey struct{}
+
+type ConfEntryKey struct{}
+
+type ValidatorKey struct{}
+
+type InterpolationKey struct{}
+
+type DecrypterKey struct{}
+
+type SignatureKey struct{}
+
+type GrayKey struct{}
+
+type HistoryKey struct{}
+
 // Client配置项
 func TimeoutMs(time uint64) ClientOption {
 	return func(o *ClientOptions) {
@@ -247,6 +377,127 @@ func Group(g string) ConfigOption {
 	}
 }
 
+func Format(f string) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.Format = f
+	}
+}
+
+func CompareAndSwap(flag bool) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.CompareAndSwap = flag
+	}
+}
+
+func Compress(flag bool) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.Compress = flag
+	}
+}
+
+func ChunkSize(size int) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.ChunkSize = size
+	}
+}
+
+func ConfNamespaceId(id string) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.NamespaceId = id
+	}
+}
+
+func Optional(flag bool) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.Optional = flag
+	}
+}
+
+func RequiredGroup(group string) ConfigOption {
+	return func(o *ConfigOptions) {
+		o.RequiredGroup = group
+	}
+}
+
+// Snapshot配置项
+func SnapshotDir(dir string) SnapshotOption {
+	return func(o *SnapshotOptions) {
+		o.Dir = dir
+	}
+}
+
+func SnapshotMaxAge(age time.Duration) SnapshotOption {
+	return func(o *SnapshotOptions) {
+		o.MaxAge = age
+	}
+}
+
+// History配置项
+func HistorySize(size int) HistoryOption {
+	return func(o *HistoryOptions) {
+		o.Size = size
+	}
+}
+
+func HistoryDir(dir string) HistoryOption {
+	return func(o *HistoryOptions) {
+		o.Dir = dir
+	}
+}
+
+// Local配置项
+func LocalPath(path string) LocalOption {
+	return func(o *LocalOptions) {
+		o.Path = path
+	}
+}
+
+func LocalEnvPrefix(prefix string) LocalOption {
+	return func(o *LocalOptions) {
+		o.EnvPrefix = prefix
+	}
+}
+
+// Interpolation配置项
+func WithResolver(prefix string, r Resolver) InterpolationOption {
+	return func(o *InterpolationOptions) {
+		o.Resolvers[prefix] = r
+	}
+}
+
+// Signature配置项
+func SignatureVerifier(v signature.Verifier) SignatureOption {
+	return func(o *SignatureOptions) {
+		o.Verifier = v
+	}
+}
+
+func SignatureSuffix(suffix string) SignatureOption {
+	return func(o *SignatureOptions) {
+		o.Suffix = suffix
+	}
+}
+
+// Gray配置项
+func GrayAttribute(key, value string) GrayOption {
+	return func(o *GrayOptions) {
+		o.Attributes[key] = value
+	}
+}
+
+func GrayVariant(variant string, match map[string]string) GrayOption {
+	return func(o *GrayOptions) {
+		o.Rules = append(o.Rules, GrayRule{Variant: variant, Match: match})
+	}
+}
+
+// Cache配置项
+func CacheTTL(ttl time.Duration) CacheOption {
+	return func(o *CacheOptions) {
+		o.TTL = ttl
+	}
+}
+
 func Client(cliOpts ...ClientOption) registry.Option {
 	return func(o *registry.Options) {
 		if o.Context == nil {
@@ -274,6 +525,74 @@ func Instance(insOpts ...InstanceOption) registry.Option {
 	}
 }
 
+// 开启服务发现的磁盘快照，nacos不可用时GetService从快照中返回上一次获取的服务
+func Snapshot(snapOpts ...SnapshotOption) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, SnapshotKey{}, snapOpts)
+	}
+}
+
+// 开启nacosRegistry内置的服务缓存，缓存由订阅推送更新
+func Cache(cacheOpts ...CacheOption) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, CacheKey{}, cacheOpts)
+	}
+}
+
+// 指定registry与watcher使用的指标
+func Metrics(m metrics.Metrics) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, MetricsKey{}, m)
+	}
+}
+
+// 指定registry与watcher调用前后执行的hook
+func Hooks(hooks ...hook.Hook) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, HookKey{}, hook.Hooks(hooks))
+	}
+}
+
+// 直接指定namingClient，设置后不再根据Server配置创建，主要用于测试
+func NamingClient(naming naming_client.INamingClient) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, NamingClientKey{}, naming)
+	}
+}
+
+func SelectorMetrics(m metrics.Metrics) selector.Option {
+	return func(o *selector.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, MetricsKey{}, m)
+	}
+}
+
+func SelectorHooks(hooks ...hook.Hook) selector.Option {
+	return func(o *selector.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, HookKey{}, hook.Hooks(hooks))
+	}
+}
+
 func ConfClient(cliOpts ...ClientOption) source.Option {
 	return func(o *source.Options) {
 		if o.Context == nil {
@@ -300,3 +619,142 @@ func ConfParam(confOpts ...ConfigOption) source.Option {
 		o.Context = context.WithValue(o.Context, ConfParamKey{}, confOpts)
 	}
 }
+
+// ConfEntry 为合并配置源添加一项，后添加的项覆盖先添加的项
+func ConfEntry(confOpts ...ConfigOption) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		entries, _ := o.Context.Value(ConfEntryKey{}).([][]ConfigOption)
+		entries = append(entries[:len(entries):len(entries)], confOpts)
+		o.Context = context.WithValue(o.Context, ConfEntryKey{}, entries)
+	}
+}
+
+// 开启配置的本地快照，nacos不可用时Read返回上一次成功读取的配置
+func ConfSnapshot(snapOpts ...SnapshotOption) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, SnapshotKey{}, snapOpts)
+	}
+}
+
+// 开启配置的本地历史，可通过config.Pin回滚到之前交付过的版本
+func ConfHistory(histOpts ...HistoryOption) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, HistoryKey{}, histOpts)
+	}
+}
+
+// 配置的校验，按顺序执行，推送的配置未通过校验时不会交给go-micro config
+func ConfValidators(validators ...ConfigValidator) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, ValidatorKey{}, validators)
+	}
+}
+
+// 开启配置内容的占位符替换，支持${ENV:default}、${a.b}引用同一配置中的key与WithResolver注册的前缀
+func ConfInterpolation(interOpts ...InterpolationOption) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, InterpolationKey{}, interOpts)
+	}
+}
+
+// 解密cipher-前缀的DataId与配置中ENC(...)的值，不依赖OpenKMS，二者不要同时开启
+func ConfDecrypter(d cipher.Decrypter) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, DecrypterKey{}, d)
+	}
+}
+
+// 开启配置签名的校验，没有签名或签名无效的配置被拒绝
+func ConfSignature(sigOpts ...SignatureOption) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, SignatureKey{}, sigOpts)
+	}
+}
+
+// 开启灰度配置，灰度DataId为在基础DataId的扩展名前加上".variant"，如gateway.canary.yaml
+// 灰度DataId不存在时使用基础DataId，Write、Delete、Pin与History作用于当前使用的DataId
+// nacos-sdk-go v1.0.7不支持Tag与beta发布，灰度配置需要以上述DataId单独发布
+func ConfGray(grayOpts ...GrayOption) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, GrayKey{}, grayOpts)
+	}
+}
+
+// 直接指定configClient，设置后不再根据ConfServer配置创建，主要用于测试
+func ConfigClient(config config_client.IConfigClient) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, ConfigClientKey{}, config)
+	}
+}
+
+// 直接指定某个命名空间的configClient，用于NewMergedSource中其他命名空间的配置项
+func NamespaceConfigClient(namespace string, config config_client.IConfigClient) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		old, _ := o.Context.Value(NamespaceClientKey{}).(map[string]config_client.IConfigClient)
+		configs := make(map[string]config_client.IConfigClient, len(old)+1)
+		for ns, c := range old {
+			configs[ns] = c
+		}
+		configs[namespace] = config
+		o.Context = context.WithValue(o.Context, NamespaceClientKey{}, configs)
+	}
+}
+
+// 从多个命名空间读取相同的配置项，按声明顺序合并，后面的命名空间覆盖前面的
+// 未指定ConfNamespaceId的每个ConfEntry(或ConfParam)在每个命名空间中各读取一次
+func ConfNamespaces(namespaces ...string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, NamespacesKey{}, namespaces)
+	}
+}
+
+func ConfMetrics(m metrics.Metrics) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, MetricsKey{}, m)
+	}
+}
+
+func ConfHooks(hooks ...hook.Hook) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, HookKey{}, hook.Hooks(hooks))
+	}
+}
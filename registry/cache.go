@@ -0,0 +1,243 @@
+package registry
+
+import (
+	"github.com/asim/go-micro/v3/logger"
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"golang.org/x/sync/singleflight"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats 内置缓存的命中统计，Stale为命中但已过期、触发后台刷新的次数
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Stale  uint64
+}
+
+type cacheEntry struct {
+	services []*registry.Service
+	updated  time.Time
+}
+
+// serviceCache 由订阅推送更新的服务缓存，过期后返回旧数据并在后台刷新
+type serviceCache struct {
+	ttl    time.Duration
+	naming naming_client.INamingClient
+	mu     sync.RWMutex
+	// key为服务名
+	entries map[string]*cacheEntry
+	// 已订阅的服务
+	subscribed map[string]*vo.SubscribeParam
+	// 每个服务收到推送的次数，开始于推送之前的刷新结果不再写入缓存
+	pushes map[string]uint64
+	closed bool
+	// 合并同一服务的并发请求
+	group singleflight.Group
+
+	hits   uint64
+	misses uint64
+	stale  uint64
+}
+
+func newServiceCache(naming naming_client.INamingClient, ttl time.Duration) *serviceCache {
+	return &serviceCache{
+		ttl:        ttl,
+		naming:     naming,
+		entries:    make(map[string]*cacheEntry),
+		subscribed: make(map[string]*vo.SubscribeParam),
+		pushes:     make(map[string]uint64),
+	}
+}
+
+func (c *serviceCache) get(service string, fetch func() ([]*registry.Service, error)) ([]*registry.Service, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[service]
+	c.mu.RUnlock()
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+		if time.Since(entry.updated) > c.ttl {
+			atomic.AddUint64(&c.stale, 1)
+			// 不等待刷新结果
+			c.group.DoChan(service, func() (interface{}, error) {
+				return c.refresh(service, fetch)
+			})
+		}
+		return copyServices(entry.services), nil
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+	v, err, _ := c.group.Do(service, func() (interface{}, error) {
+		return c.refresh(service, fetch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.subscribe(service)
+	return copyServices(v.([]*registry.Service)), nil
+}
+
+func (c *serviceCache) refresh(service string, fetch func() ([]*registry.Service, error)) (interface{}, error) {
+	c.mu.RLock()
+	pushes := c.pushes[service]
+	c.mu.RUnlock()
+	services, err := fetch()
+	if err == registry.ErrNotFound {
+		c.mu.Lock()
+		if c.pushes[service] == pushes {
+			delete(c.entries, service)
+		}
+		c.mu.Unlock()
+		return nil, err
+	}
+	if err != nil {
+		logger.Logf(logger.WarnLevel, "nacos cache refresh %s failed, err:%v", service, err)
+		return nil, err
+	}
+	// 来自快照的数据不写入缓存，nacos恢复后重新获取
+	if len(services) > 0 && services[0].Metadata[SnapshotMetaKey] == "true" {
+		return services, nil
+	}
+	c.mu.Lock()
+	// 刷新期间收到了推送，推送的数据更新
+	if c.pushes[service] == pushes {
+		c.set(service, services)
+	}
+	c.mu.Unlock()
+	return services, nil
+}
+
+// set 调用方持有mu
+func (c *serviceCache) set(service string, services []*registry.Service) {
+	c.entries[service] = &cacheEntry{
+		services: services,
+		updated:  time.Now(),
+	}
+}
+
+// subscribe 订阅服务，由推送直接更新缓存
+func (c *serviceCache) subscribe(service string) {
+	c.mu.Lock()
+	if _, ok := c.subscribed[service]; ok || c.closed {
+		c.mu.Unlock()
+		return
+	}
+	param := &vo.SubscribeParam{
+		ServiceName: service,
+		SubscribeCallback: func(services []model.SubscribeService, err error) {
+			c.onPush(service, services, err)
+		},
+	}
+	c.subscribed[service] = param
+	c.mu.Unlock()
+	if err := c.naming.Subscribe(param); err != nil {
+		logger.Logf(logger.WarnLevel, "nacos cache subscribe %s failed, err:%v", service, err)
+		c.mu.Lock()
+		delete(c.subscribed, service)
+		c.mu.Unlock()
+	}
+}
+
+func (c *serviceCache) onPush(service string, services []model.SubscribeService, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pushes[service]++
+	// sdk在服务下没有实例时回调空列表和error
+	if err != nil || len(services) == 0 {
+		delete(c.entries, service)
+		return
+	}
+	var md map[string]string
+	if entry, ok := c.entries[service]; ok && len(entry.services) > 0 {
+		md = entry.services[0].Metadata
+	}
+	nodes := make([]*registry.Node, len(services))
+	for i, node := range services {
+		nodes[i] = &registry.Node{
+			Id:       node.InstanceId,
+			Address:  node.Ip + ":" + strconv.Itoa(int(node.Port)),
+			Metadata: node.Metadata,
+		}
+	}
+	c.set(service, []*registry.Service{{
+		Name:     service,
+		Metadata: md,
+		Nodes:    nodes,
+	}})
+}
+
+// close 取消所有订阅，之后的读取不再订阅
+func (c *serviceCache) close() error {
+	c.mu.Lock()
+	c.closed = true
+	subscribed := c.subscribed
+	c.subscribed = make(map[string]*vo.SubscribeParam)
+	c.mu.Unlock()
+	var err error
+	for service, param := range subscribed {
+		if e := c.naming.Unsubscribe(param); e != nil {
+			logger.Logf(logger.WarnLevel, "nacos cache unsubscribe %s failed, err:%v", service, e)
+			err = e
+		}
+	}
+	return err
+}
+
+func (c *serviceCache) stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+		Stale:  atomic.LoadUint64(&c.stale),
+	}
+}
+
+// 调用方(如selector)可能会修改返回的节点列表与Metadata，因此返回副本
+func copyServices(services []*registry.Service) []*registry.Service {
+	result := make([]*registry.Service, len(services))
+	for i, s := range services {
+		srv := *s
+		srv.Metadata = copyMetadata(s.Metadata)
+		srv.Nodes = make([]*registry.Node, len(s.Nodes))
+		for j, n := range s.Nodes {
+			node := *n
+			node.Metadata = copyMetadata(n.Metadata)
+			srv.Nodes[j] = &node
+		}
+		result[i] = &srv
+	}
+	return result
+}
+
+func copyMetadata(md map[string]string) map[string]string {
+	if md == nil {
+		return nil
+	}
+	result := make(map[string]string, len(md))
+	for k, v := range md {
+		result[k] = v
+	}
+	return result
+}
+
+// Close 取消registry内置缓存的所有订阅，未开启缓存时直接返回
+func Close(r registry.Registry) error {
+	n, ok := r.(*nacosRegistry)
+	if !ok || n.cache == nil {
+		return nil
+	}
+	return n.cache.close()
+}
+
+// Stats 返回registry内置缓存的统计，未开启缓存时ok为false
+func Stats(r registry.Registry) (stats CacheStats, ok bool) {
+	n, ok := r.(*nacosRegistry)
+	if !ok || n.cache == nil {
+		return CacheStats{}, false
+	}
+	return n.cache.stats(), true
+}
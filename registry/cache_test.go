@@ -0,0 +1,228 @@
+package registry
+
+import (
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingNaming 统计GetService的调用次数
+type countingNaming struct {
+	*mock.NamingClient
+	calls int64
+	// 不为nil时GetService读取后通知fetched，并等待hold关闭再返回，模拟返回途中的旧数据
+	hold    chan struct{}
+	fetched chan struct{}
+}
+
+func (c *countingNaming) GetService(param vo.GetServiceParam) (model.Service, error) {
+	atomic.AddInt64(&c.calls, 1)
+	service, err := c.NamingClient.GetService(param)
+	if c.hold != nil {
+		c.fetched <- struct{}{}
+		<-c.hold
+	}
+	return service, err
+}
+
+// block 之后的GetService读取后等待hold关闭
+func (c *countingNaming) block() {
+	c.hold = make(chan struct{})
+	c.fetched = make(chan struct{}, 16)
+}
+
+// waitFetched 等待一次GetService读取nacos的数据
+func (c *countingNaming) waitFetched(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.fetched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetService not called")
+	}
+}
+
+// waitRefresh 等待service正在进行的刷新结束
+func waitRefresh(t *testing.T, reg registry.Registry, service string) {
+	t.Helper()
+	done := reg.(*nacosRegistry).cache.group.DoChan(service, func() (interface{}, error) {
+		return nil, nil
+	})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh not finished")
+	}
+}
+
+func newCacheRegistry(naming *countingNaming, ttl time.Duration) registry.Registry {
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "cache", Ip: "10.0.0.1", Port: 80})
+	return NewRegistry(
+		nacos.Client(nacos.NamespaceId("cache")),
+		nacos.Instance(nacos.Weight(10), nacos.Enable(true), nacos.Healthy(true)),
+		nacos.NamingClient(naming),
+		nacos.Cache(nacos.CacheTTL(ttl)),
+	)
+}
+
+func TestCacheHitMiss(t *testing.T) {
+	naming := &countingNaming{NamingClient: mock.NewNamingClient()}
+	reg := newCacheRegistry(naming, time.Minute)
+	for i := 0; i < 3; i++ {
+		services, err := reg.GetService("cache")
+		if err != nil || len(services[0].Nodes) != 1 {
+			t.Fatalf("unexpected services %v, err: %v", services, err)
+		}
+		// 修改返回值不影响缓存
+		services[0].Nodes = nil
+	}
+	stats, ok := Stats(reg)
+	if !ok || stats.Misses != 1 || stats.Hits != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if atomic.LoadInt64(&naming.calls) != 1 {
+		t.Fatalf("expected 1 call to nacos, got %d", naming.calls)
+	}
+}
+
+func TestCachePush(t *testing.T) {
+	naming := &countingNaming{NamingClient: mock.NewNamingClient()}
+	reg := newCacheRegistry(naming, time.Minute)
+	if _, err := reg.GetService("cache"); err != nil {
+		t.Fatal(err)
+	}
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "cache", Ip: "10.0.0.2", Port: 80})
+	services, err := reg.GetService("cache")
+	if err != nil || len(services[0].Nodes) != 2 {
+		t.Fatalf("expected pushed nodes, got %v, err: %v", services, err)
+	}
+	naming.DeregisterInstance(vo.DeregisterInstanceParam{ServiceName: "cache", Ip: "10.0.0.1", Port: 80})
+	naming.DeregisterInstance(vo.DeregisterInstanceParam{ServiceName: "cache", Ip: "10.0.0.2", Port: 80})
+	if _, err := reg.GetService("cache"); err != registry.ErrNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	naming := &countingNaming{NamingClient: mock.NewNamingClient()}
+	// 写入后立即过期
+	reg := newCacheRegistry(naming, time.Nanosecond)
+	if _, err := reg.GetService("cache"); err != nil {
+		t.Fatal(err)
+	}
+	naming.block()
+	done := make(chan error, 1)
+	go func() {
+		_, err := reg.GetService("cache")
+		done <- err
+	}()
+	// 后台刷新未返回时，过期的缓存直接返回
+	naming.waitFetched(t)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stale read waited for refresh")
+	}
+	close(naming.hold)
+	waitRefresh(t, reg, "cache")
+	if stats, _ := Stats(reg); stats.Stale != 1 || atomic.LoadInt64(&naming.calls) != 2 {
+		t.Fatalf("expected one background refresh, stats %+v, calls %d", stats, naming.calls)
+	}
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	naming := &countingNaming{NamingClient: mock.NewNamingClient()}
+	naming.block()
+	reg := newCacheRegistry(naming, time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := reg.GetService("cache"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// 第一次读取返回前，其余的读取合并到同一次请求
+	naming.waitFetched(t)
+	close(naming.hold)
+	wg.Wait()
+	if atomic.LoadInt64(&naming.calls) != 1 {
+		t.Fatalf("expected concurrent misses to be collapsed, got %d calls", naming.calls)
+	}
+}
+
+func TestCacheRefreshAfterPush(t *testing.T) {
+	naming := &countingNaming{NamingClient: mock.NewNamingClient()}
+	reg := newCacheRegistry(naming, time.Nanosecond)
+	if _, err := reg.GetService("cache"); err != nil {
+		t.Fatal(err)
+	}
+	naming.block()
+	if _, err := reg.GetService("cache"); err != nil {
+		t.Fatal(err)
+	}
+	// 后台刷新读到旧数据后，推送先到达
+	naming.waitFetched(t)
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "cache", Ip: "10.0.0.2", Port: 80})
+	close(naming.hold)
+	waitRefresh(t, reg, "cache")
+	services, err := reg.GetService("cache")
+	if err != nil || len(services[0].Nodes) != 2 {
+		t.Fatalf("refresh overwrote pushed nodes: %v, err: %v", services, err)
+	}
+}
+
+func TestCacheCopyMetadata(t *testing.T) {
+	naming := &countingNaming{NamingClient: mock.NewNamingClient()}
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "cache", Ip: "10.0.0.2", Port: 80, Metadata: map[string]string{"zone": "a"}})
+	reg := newCacheRegistry(naming, time.Minute)
+	services, err := reg.GetService("cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range services[0].Nodes {
+		if node.Metadata != nil {
+			node.Metadata["zone"] = "b"
+		}
+	}
+	services, _ = reg.GetService("cache")
+	zones := 0
+	for _, node := range services[0].Nodes {
+		if node.Metadata["zone"] == "b" {
+			t.Fatalf("cache metadata modified by caller: %v", node.Metadata)
+		}
+		if node.Metadata["zone"] == "a" {
+			zones++
+		}
+	}
+	if zones != 1 {
+		t.Fatalf("unexpected nodes %v", services[0].Nodes)
+	}
+}
+
+func TestCacheClose(t *testing.T) {
+	naming := &countingNaming{NamingClient: mock.NewNamingClient()}
+	reg := newCacheRegistry(naming, time.Minute)
+	if _, err := reg.GetService("cache"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(reg); err != nil {
+		t.Fatal(err)
+	}
+	// 取消订阅后不再收到推送
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "cache", Ip: "10.0.0.2", Port: 80})
+	services, err := reg.GetService("cache")
+	if err != nil || len(services[0].Nodes) != 1 {
+		t.Fatalf("unexpected services after close %v, err: %v", services, err)
+	}
+}
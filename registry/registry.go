@@ -3,14 +3,18 @@ package registry
 import (
 	"errors"
 	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/metrics"
 	"github.com/asim/go-micro/v3/logger"
 	"github.com/asim/go-micro/v3/registry"
+	"github.com/asim/go-micro/v3/registry/cache"
 	"github.com/nacos-group/nacos-sdk-go/clients"
 	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
 	"github.com/nacos-group/nacos-sdk-go/common/constant"
 	"github.com/nacos-group/nacos-sdk-go/model"
 	"github.com/nacos-group/nacos-sdk-go/vo"
 	"net"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -30,6 +34,14 @@ type nacosRegistry struct {
 	serviceChan chan string
 	// 标记是否调用过Watch
 	watchFlag bool
+	// 服务发现的磁盘快照，未开启时为nil
+	snapshot *snapshot
+	// 内置的服务缓存，未开启时为nil
+	cache   *serviceCache
+	metrics metrics.Metrics
+	hooks   hook.Hooks
+	// 记录每次调用的hook与指标
+	observer hook.Observer
 }
 
 func NewRegistry(opts ...registry.Option) registry.Registry {
@@ -62,6 +74,31 @@ func configure(n *nacosRegistry, opts ...registry.Option) error {
 		return errors.New("missing client options")
 	}
 
+	n.metrics = metrics.Noop()
+	if m, ok := n.options.Context.Value(nacos.MetricsKey{}).(metrics.Metrics); ok {
+		n.metrics = m
+	}
+	if hooks, ok := n.options.Context.Value(nacos.HookKey{}).(hook.Hooks); ok {
+		n.hooks = hooks
+	}
+	n.observer = hook.Observer{Hooks: n.hooks, Metrics: n.metrics, Requests: metrics.RegistryRequests, Latency: metrics.RegistryLatency}
+
+	// 初始化快照，默认目录为CacheDir下按namespace区分的snapshot目录
+	if snapOpts, ok := n.options.Context.Value(nacos.SnapshotKey{}).([]nacos.SnapshotOption); ok {
+		snapOptions := nacos.SnapshotOptions{
+			Dir: filepath.Join(n.client.CacheDir, "snapshot", n.client.NamespaceId),
+		}
+		for _, snapOpt := range snapOpts {
+			snapOpt(&snapOptions)
+		}
+		n.snapshot = newSnapshot(snapOptions.Dir, snapOptions.MaxAge)
+	}
+
+	// 若直接指定了namingClient，则无需server配置
+	if naming, ok := n.options.Context.Value(nacos.NamingClientKey{}).(naming_client.INamingClient); ok {
+		n.naming = naming
+	}
+
 	// 初始化server
 	var defIp = "127.0.0.1"
 	var defPort uint64 = 8848
@@ -76,7 +113,7 @@ func configure(n *nacosRegistry, opts ...registry.Option) error {
 			}
 			n.server = append(n.server, srvOptions)
 		}
-	} else {
+	} else if n.naming == nil {
 		return errors.New("missing server options")
 	}
 
@@ -89,19 +126,35 @@ func configure(n *nacosRegistry, opts ...registry.Option) error {
 		return errors.New("missing instance options")
 	}
 
-	// 生成namingClient
-	serverConfigs := make([]constant.ServerConfig, 0)
-	for _, s := range n.server {
-		serverConfigs = append(serverConfigs, s.ServerConfig)
+	if n.naming == nil {
+		// 生成namingClient
+		serverConfigs := make([]constant.ServerConfig, 0)
+		for _, s := range n.server {
+			serverConfigs = append(serverConfigs, s.ServerConfig)
+		}
+		var err error
+		n.naming, err = clients.NewNamingClient(
+			vo.NacosClientParam{
+				ClientConfig:  &n.client.ClientConfig,
+				ServerConfigs: serverConfigs,
+			},
+		)
+		if err != nil {
+			return err
+		}
 	}
-	var err error
-	n.naming, err = clients.NewNamingClient(
-		vo.NacosClientParam{
-			ClientConfig:  &n.client.ClientConfig,
-			ServerConfigs: serverConfigs,
-		},
-	)
-	return err
+
+	// 初始化内置缓存
+	if cacheOpts, ok := n.options.Context.Value(nacos.CacheKey{}).([]nacos.CacheOption); ok {
+		cacheOptions := nacos.CacheOptions{
+			TTL: cache.DefaultTTL,
+		}
+		for _, cacheOpt := range cacheOpts {
+			cacheOpt(&cacheOptions)
+		}
+		n.cache = newServiceCache(n.naming, cacheOptions.TTL)
+	}
+	return nil
 }
 
 // 不要调用该函数，通过NewRegistry完成初始化
@@ -119,7 +172,7 @@ func (n *nacosRegistry) updateInstance(s *registry.Service) error {
 		return errors.New("require service owning at least one node")
 	}
 	node := s.Nodes[0]
-	_, portStr, err := net.SplitHostPort(node.Address)
+	host, portStr, err := net.SplitHostPort(node.Address)
 	if err != nil {
 		return err
 	}
@@ -127,22 +180,35 @@ func (n *nacosRegistry) updateInstance(s *registry.Service) error {
 	if err != nil {
 		return err
 	}
-	ip := localIP()
+	// 节点地址为具体ip时直接使用，否则(如[::]:port)取本机ip
+	ip := host
+	if addr := net.ParseIP(host); addr == nil || addr.IsUnspecified() {
+		ip = nacos.LocalIP()
+	}
 	if ip == "" {
 		return errors.New("network failed")
 	}
+	if n.instance.ServiceName == "" {
+		n.instance.ServiceName = s.Name
+	}
 	n.instance.Ip = ip
 	n.instance.Port = uint64(port)
 	return nil
 }
 
 // Register和Deregister都只负责当前Service的注册和撤销
-func (n *nacosRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+func (n *nacosRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) (err error) {
+	options := registry.RegisterOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	_, done := n.observer.Observe(options.Context, hook.RegistryRegister, "register", map[string]string{"service": s.Name})
+	defer func() { done(err) }()
 	if n.naming == nil {
 		return errors.New("nacos registry hasn't been initialized")
 	}
 
-	err := n.updateInstance(s)
+	err = n.updateInstance(s)
 	if err != nil {
 		return err
 	}
@@ -157,7 +223,13 @@ func (n *nacosRegistry) Register(s *registry.Service, opts ...registry.RegisterO
 	return nil
 }
 
-func (n *nacosRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+func (n *nacosRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) (err error) {
+	options := registry.DeregisterOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	_, done := n.observer.Observe(options.Context, hook.RegistryDeregister, "deregister", map[string]string{"service": s.Name})
+	defer func() { done(err) }()
 	if n.naming == nil {
 		return errors.New("nacos registry hasn't been initialized")
 	}
@@ -170,7 +242,7 @@ func (n *nacosRegistry) Deregister(s *registry.Service, opts ...registry.Deregis
 		Ephemeral:   n.instance.Ephemeral,
 	}
 	logger.Log(logger.InfoLevel, "nacos starting deregister")
-	_, err := n.naming.DeregisterInstance(param)
+	_, err = n.naming.DeregisterInstance(param)
 	if err != nil {
 		logger.Logf(logger.ErrorLevel, "nacos deregistered failed, err: %v", err)
 	}
@@ -178,13 +250,28 @@ func (n *nacosRegistry) Deregister(s *registry.Service, opts ...registry.Deregis
 	return err
 }
 
-func (n *nacosRegistry) GetService(s string, opts ...registry.GetOption) ([]*registry.Service, error) {
+func (n *nacosRegistry) GetService(s string, opts ...registry.GetOption) (services []*registry.Service, err error) {
+	options := registry.GetOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	_, done := n.observer.Observe(options.Context, hook.RegistryGetService, "get_service", map[string]string{"service": s})
+	defer func() { done(err) }()
 	if n.naming == nil {
 		return nil, errors.New("nacos registry hasn't been initialized")
 	}
 
 	s = divideNamespace(s)
+	if n.cache != nil {
+		return n.cache.get(s, func() ([]*registry.Service, error) {
+			return n.getService(s)
+		})
+	}
+	return n.getService(s)
+}
 
+// 直接从nacos获取服务，失败时尝试使用快照
+func (n *nacosRegistry) getService(s string) ([]*registry.Service, error) {
 	// TODO:考虑是否将clusters与groupName设置为和n.Instance相同的属性
 	param := vo.GetServiceParam{
 		Clusters:    nil,
@@ -195,9 +282,33 @@ func (n *nacosRegistry) GetService(s string, opts ...registry.GetOption) ([]*reg
 	service, err := n.naming.GetService(param)
 	if err != nil {
 		logger.Logf(logger.ErrorLevel, "nacos getservice failed, err:%v", err)
+		// nacos不可用时尝试从快照返回
+		if n.snapshot != nil {
+			if services, snapErr := n.snapshot.load(s); snapErr == nil {
+				logger.Logf(logger.WarnLevel, "nacos getservice %s served from snapshot", s)
+				return services, nil
+			}
+		}
 		return nil, err
 	}
 
+	// 更新服务set, 并将新的service推入serviceChan中
+	// 思考是否放在另外一个goroutine会更好
+	n.mu.Lock()
+	if _, ok := n.services[s]; !ok {
+		n.services[s] = struct{}{}
+		// 检查是否开启了watcher
+		if n.watchFlag {
+			n.serviceChan <- s
+		}
+	}
+	n.mu.Unlock()
+
+	n.metrics.Gauge(metrics.RegistryNodes, metrics.Labels{"service": s}, float64(len(service.Hosts)))
+	if len(service.Hosts) == 0 {
+		return nil, registry.ErrNotFound
+	}
+
 	nodes := make([]*registry.Node, 0, len(service.Hosts))
 	for _, host := range service.Hosts {
 		node := &registry.Node{
@@ -209,28 +320,28 @@ func (n *nacosRegistry) GetService(s string, opts ...registry.GetOption) ([]*reg
 	}
 
 	rService := &registry.Service{
-		Name:      service.Name,
+		Name:      s,
 		Version:   "",
 		Metadata:  service.Metadata,
 		Endpoints: nil,
 		Nodes:     nodes,
 	}
-	// 更新服务set, 并将新的service推入serviceChan中
-	// 思考是否放在另外一个goroutine会更好
-	n.mu.Lock()
-	if _, ok := n.services[s]; !ok {
-		n.services[s] = struct{}{}
-		// 检查是否开启了watcher
-		if n.watchFlag {
-			n.serviceChan <- s
+	services := []*registry.Service{rService}
+	if n.snapshot != nil {
+		if err := n.snapshot.save(s, services); err != nil {
+			logger.Logf(logger.WarnLevel, "nacos save snapshot of %s failed, err:%v", s, err)
 		}
 	}
-	n.mu.Unlock()
-
-	return []*registry.Service{rService}, nil
+	return services, nil
 }
 
-func (n *nacosRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+func (n *nacosRegistry) ListServices(opts ...registry.ListOption) (services []*registry.Service, err error) {
+	options := registry.ListOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	ctx, done := n.observer.Observe(options.Context, hook.RegistryListServices, "list_services", nil)
+	defer func() { done(err) }()
 	if n.naming == nil {
 		return nil, errors.New("nacos registry hasn't been initialized")
 	}
@@ -244,16 +355,19 @@ func (n *nacosRegistry) ListServices(opts ...registry.ListOption) ([]*registry.S
 		PageNo:    page,
 		PageSize:  10000, // 一次性读完所有服务
 	}
-	serviceList, err := n.naming.GetAllServicesInfo(param)
+	serviceList, err = n.naming.GetAllServicesInfo(param)
 	if err != nil {
 		logger.Logf(logger.ErrorLevel, "nacos listServices failed, err:%v", err)
 		return nil, err
 	}
 	serviceNames = append(serviceNames, serviceList.Doms...)
 
-	services := []*registry.Service{}
+	services = []*registry.Service{}
 	for _, name := range serviceNames {
-		if tmpServices, err := n.GetService(name); err != nil {
+		if tmpServices, err := n.GetService(name, registry.GetContext(ctx)); err == registry.ErrNotFound {
+			// 服务下已没有实例
+			continue
+		} else if err != nil {
 			return nil, err
 		} else {
 			services = append(services, tmpServices...)
@@ -264,9 +378,16 @@ func (n *nacosRegistry) ListServices(opts ...registry.ListOption) ([]*registry.S
 }
 
 func (n *nacosRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
-	n.mu.Lock()
-	n.watchFlag = true
-	n.mu.Unlock()
+	watchOptions := registry.WatchOptions{}
+	for _, opt := range opts {
+		opt(&watchOptions)
+	}
+	// 指定了服务的watcher直接订阅该服务，不消费serviceChan
+	if watchOptions.Service == "" {
+		n.mu.Lock()
+		n.watchFlag = true
+		n.mu.Unlock()
+	}
 	return newNacosWatcher(n, opts...)
 }
 
@@ -274,22 +395,6 @@ func (n *nacosRegistry) String() string {
 	return "nacos"
 }
 
-func localIP() string {
-	conn, err := net.Dial("udp", "8.8.8.8:80")
-	if err != nil || conn == nil {
-		return ""
-	}
-	defer func() {
-		if conn != nil {
-			conn.Close()
-		}
-	}()
-
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-
-	return localAddr.IP.String()
-}
-
 // 拆分命名空间，eg: test.Stest1 返回test作为主要命名空间 test.Stest1.Stest2 返回test.Stest1作为主要命名空间
 func divideNamespace(s string) string {
 	ind := strings.LastIndex(s, ".")
@@ -1 +1,104 @@
 package registry
+
+import (
+	"context"
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/hook/trace"
+	"github.com/DMwangnima/nacos-plugin/metrics"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/DMwangnima/nacos-plugin/registry/registrytest"
+	"github.com/asim/go-micro/v3/registry"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	naming := mock.NewNamingClient()
+	registrytest.Run(t, func() registry.Registry {
+		return NewRegistry(
+			nacos.Client(nacos.NamespaceId("registrytest")),
+			nacos.Instance(
+				nacos.Weight(10),
+				nacos.Enable(true),
+				nacos.Healthy(true),
+				nacos.Ephemeral(true),
+			),
+			nacos.NamingClient(naming),
+		)
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	m := metrics.NewMemory()
+	reg := NewRegistry(
+		nacos.Client(nacos.NamespaceId("metrics")),
+		nacos.Instance(nacos.Weight(10), nacos.Enable(true), nacos.Healthy(true)),
+		nacos.NamingClient(mock.NewNamingClient()),
+		nacos.Metrics(m),
+	)
+	s := &registry.Service{
+		Name:  "metrics",
+		Nodes: []*registry.Node{{Id: "1", Address: "10.0.0.1:80"}},
+	}
+	if err := reg.Register(s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.GetService("metrics"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.GetService("unknown"); err != registry.ErrNotFound {
+		t.Fatal(err)
+	}
+	if v := m.CounterValue(metrics.RegistryRequests, metrics.Labels{"op": "register", "status": "ok"}); v != 1 {
+		t.Fatalf("expected 1 register, got %v", v)
+	}
+	if v := m.CounterValue(metrics.RegistryRequests, metrics.Labels{"op": "get_service", "status": "error"}); v != 1 {
+		t.Fatalf("expected 1 failed get_service, got %v", v)
+	}
+	if v := m.GaugeValue(metrics.RegistryNodes, metrics.Labels{"service": "metrics"}); v != 1 {
+		t.Fatalf("expected 1 node, got %v", v)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	recorder := trace.NewRecorder()
+	reg := NewRegistry(
+		nacos.Client(nacos.NamespaceId("hooks")),
+		nacos.Instance(nacos.Weight(10), nacos.Enable(true), nacos.Healthy(true)),
+		nacos.NamingClient(mock.NewNamingClient()),
+		nacos.Hooks(trace.NewHook(recorder)),
+	)
+	s := &registry.Service{
+		Name:  "hooks",
+		Nodes: []*registry.Node{{Id: "1", Address: "10.0.0.1:80"}},
+	}
+	if err := reg.Register(s); err != nil {
+		t.Fatal(err)
+	}
+	ctx, parent := recorder.Start(context.Background(), "request")
+	if _, err := reg.GetService("hooks", registry.GetContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := recorder.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	if spans[0].Name != hook.RegistryRegister || spans[0].Attributes["service"] != "hooks" {
+		t.Fatalf("unexpected span %+v", spans[0])
+	}
+	if spans[1].Name != hook.RegistryGetService || spans[1].Parent != spans[2] {
+		t.Fatalf("unexpected span %+v", spans[1])
+	}
+
+	// ListServices中获取每个服务的span是ListServices的子span
+	recorder.Reset()
+	if _, err := reg.ListServices(); err != nil {
+		t.Fatal(err)
+	}
+	spans = recorder.Spans()
+	if len(spans) != 2 || spans[1].Name != hook.RegistryListServices || spans[0].Parent != spans[1] {
+		t.Fatalf("unexpected spans %+v", spans)
+	}
+}
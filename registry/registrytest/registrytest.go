@@ -0,0 +1,283 @@
+// registrytest 提供registry.Registry的一致性测试，检查注册、查询、撤销、列举与watch的语义，
+// 可同时用于本插件与go-micro的memory registry
+package registrytest
+
+import (
+	"github.com/asim/go-micro/v3/registry"
+	"testing"
+	"time"
+)
+
+// 等待watch事件的超时时间
+var EventTimeout = 5 * time.Second
+
+// NewRegistry 返回待测试的registry，多次调用返回的registry必须共享同一个后端，
+// 相当于同一个注册中心下的多个进程；也可以每次都返回同一个实例
+type NewRegistry func() registry.Registry
+
+// Run 依次执行所有一致性测试，每个子测试使用独立的服务名
+func Run(t *testing.T, newRegistry NewRegistry) {
+	t.Run("RegisterGet", func(t *testing.T) { testRegisterGet(t, newRegistry) })
+	t.Run("DeregisterGet", func(t *testing.T) { testDeregisterGet(t, newRegistry) })
+	t.Run("ListServices", func(t *testing.T) { testListServices(t, newRegistry) })
+	t.Run("MultipleNodes", func(t *testing.T) { testMultipleNodes(t, newRegistry) })
+	t.Run("Watch", func(t *testing.T) { testWatch(t, newRegistry) })
+	t.Run("WatchStop", func(t *testing.T) { testWatchStop(t, newRegistry) })
+}
+
+func newService(name, addr string) *registry.Service {
+	return &registry.Service{
+		Name: name,
+		Nodes: []*registry.Node{
+			{
+				Id:       name + "-" + addr,
+				Address:  addr,
+				Metadata: map[string]string{"registrytest": "true"},
+			},
+		},
+	}
+}
+
+func register(t *testing.T, r registry.Registry, s *registry.Service) {
+	t.Helper()
+	if err := r.Register(s); err != nil {
+		t.Fatalf("register %s %s failed: %v", s.Name, s.Nodes[0].Address, err)
+	}
+}
+
+func deregister(t *testing.T, r registry.Registry, s *registry.Service) {
+	t.Helper()
+	if err := r.Deregister(s); err != nil {
+		t.Fatalf("deregister %s %s failed: %v", s.Name, s.Nodes[0].Address, err)
+	}
+}
+
+// 返回服务下所有节点的地址，服务不存在时返回空
+func addresses(t *testing.T, r registry.Registry, name string) map[string]struct{} {
+	t.Helper()
+	addrs := make(map[string]struct{})
+	services, err := r.GetService(name)
+	if err == registry.ErrNotFound {
+		return addrs
+	}
+	if err != nil {
+		t.Fatalf("get service %s failed: %v", name, err)
+	}
+	for _, s := range services {
+		if s.Name != name {
+			t.Fatalf("get service %s returned service %s", name, s.Name)
+		}
+		for _, n := range s.Nodes {
+			addrs[n.Address] = struct{}{}
+		}
+	}
+	return addrs
+}
+
+func hasNode(s *registry.Service, addr string) bool {
+	if s == nil {
+		return false
+	}
+	for _, n := range s.Nodes {
+		if n.Address == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func testRegisterGet(t *testing.T, newRegistry NewRegistry) {
+	r := newRegistry()
+	s := newService("registrytest-register-get", "10.0.0.1:9001")
+	register(t, r, s)
+	defer deregister(t, r, s)
+
+	addrs := addresses(t, r, s.Name)
+	if _, ok := addrs[s.Nodes[0].Address]; !ok || len(addrs) != 1 {
+		t.Fatalf("expected exactly node %s, got %v", s.Nodes[0].Address, addrs)
+	}
+}
+
+func testDeregisterGet(t *testing.T, newRegistry NewRegistry) {
+	r := newRegistry()
+	s := newService("registrytest-deregister-get", "10.0.0.1:9002")
+	register(t, r, s)
+	deregister(t, r, s)
+
+	if addrs := addresses(t, r, s.Name); len(addrs) != 0 {
+		t.Fatalf("expected no nodes after deregister, got %v", addrs)
+	}
+}
+
+func testListServices(t *testing.T, newRegistry NewRegistry) {
+	r1, r2 := newRegistry(), newRegistry()
+	s1 := newService("registrytest-list-a", "10.0.0.1:9003")
+	s2 := newService("registrytest-list-b", "10.0.0.2:9003")
+	register(t, r1, s1)
+	defer deregister(t, r1, s1)
+	register(t, r2, s2)
+	defer deregister(t, r2, s2)
+
+	services, err := r1.ListServices()
+	if err != nil {
+		t.Fatalf("list services failed: %v", err)
+	}
+	found := make(map[string]bool)
+	for _, s := range services {
+		found[s.Name] = true
+	}
+	for _, name := range []string{s1.Name, s2.Name} {
+		if !found[name] {
+			t.Fatalf("service %s missing from list", name)
+		}
+	}
+}
+
+func testMultipleNodes(t *testing.T, newRegistry NewRegistry) {
+	name := "registrytest-multiple-nodes"
+	r1, r2 := newRegistry(), newRegistry()
+	s1 := newService(name, "10.0.0.1:9004")
+	s2 := newService(name, "10.0.0.2:9004")
+	register(t, r1, s1)
+	register(t, r2, s2)
+	defer deregister(t, r2, s2)
+
+	addrs := addresses(t, r1, name)
+	if len(addrs) != 2 {
+		t.Fatalf("expected 2 nodes, got %v", addrs)
+	}
+
+	deregister(t, r1, s1)
+	addrs = addresses(t, r2, name)
+	if _, ok := addrs[s2.Nodes[0].Address]; !ok || len(addrs) != 1 {
+		t.Fatalf("expected only node %s, got %v", s2.Nodes[0].Address, addrs)
+	}
+}
+
+// events 持续读取watcher，避免注册中心因无人读取而丢弃事件
+func events(w registry.Watcher) <-chan *registry.Result {
+	ch := make(chan *registry.Result)
+	go func() {
+		defer close(ch)
+		for {
+			res, err := w.Next()
+			if err != nil {
+				return
+			}
+			ch <- res
+		}
+	}()
+	return ch
+}
+
+type eventReader struct {
+	ch   <-chan *registry.Result
+	last string
+}
+
+func eventKey(res *registry.Result) string {
+	key := res.Action
+	for _, n := range res.Service.Nodes {
+		key += " " + n.Address
+	}
+	return key
+}
+
+// next 返回下一个事件，与上一个事件完全相同的重复事件会被忽略
+func (e *eventReader) next(t *testing.T) *registry.Result {
+	t.Helper()
+	timeout := time.After(EventTimeout)
+	for {
+		select {
+		case res, ok := <-e.ch:
+			if !ok {
+				t.Fatal("watcher stopped unexpectedly")
+			}
+			if res.Service == nil {
+				t.Fatalf("event %s without service", res.Action)
+			}
+			if key := eventKey(res); key != e.last {
+				e.last = key
+				return res
+			}
+		case <-timeout:
+			t.Fatalf("no event received within %v", EventTimeout)
+		}
+	}
+}
+
+// 节点加入后，事件应为create或update，且包含该节点
+func (e *eventReader) expectAdded(t *testing.T, name, addr string) {
+	t.Helper()
+	res := e.next(t)
+	if res.Service.Name != name {
+		t.Fatalf("expected event of %s, got %s", name, res.Service.Name)
+	}
+	if (res.Action != "create" && res.Action != "update") || !hasNode(res.Service, addr) {
+		t.Fatalf("expected node %s added, got %s", addr, eventKey(res))
+	}
+}
+
+// 节点撤销后，事件应为包含该节点的delete，或不包含该节点的update
+func (e *eventReader) expectRemoved(t *testing.T, name, addr string) {
+	t.Helper()
+	res := e.next(t)
+	if res.Service.Name != name {
+		t.Fatalf("expected event of %s, got %s", name, res.Service.Name)
+	}
+	switch {
+	case res.Action == "delete" && hasNode(res.Service, addr):
+	case res.Action == "update" && !hasNode(res.Service, addr):
+	default:
+		t.Fatalf("expected node %s removed, got %s", addr, eventKey(res))
+	}
+}
+
+func testWatch(t *testing.T, newRegistry NewRegistry) {
+	name := "registrytest-watch"
+	r1, r2 := newRegistry(), newRegistry()
+	w, err := r1.Watch(registry.WatchService(name))
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	defer w.Stop()
+	e := &eventReader{ch: events(w)}
+
+	s1 := newService(name, "10.0.0.1:9005")
+	s2 := newService(name, "10.0.0.2:9005")
+	register(t, r1, s1)
+	e.expectAdded(t, name, s1.Nodes[0].Address)
+	register(t, r2, s2)
+	e.expectAdded(t, name, s2.Nodes[0].Address)
+	deregister(t, r2, s2)
+	e.expectRemoved(t, name, s2.Nodes[0].Address)
+	deregister(t, r1, s1)
+
+	// 最后一个节点撤销后应收到delete
+	res := e.next(t)
+	if res.Action != "delete" || !hasNode(res.Service, s1.Nodes[0].Address) {
+		t.Fatalf("expected delete of %s, got %s", s1.Nodes[0].Address, eventKey(res))
+	}
+}
+
+func testWatchStop(t *testing.T, newRegistry NewRegistry) {
+	r := newRegistry()
+	w, err := r.Watch(registry.WatchService("registrytest-watch-stop"))
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	w.Stop()
+	done := make(chan error)
+	go func() {
+		_, err := w.Next()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected error from stopped watcher")
+		}
+	case <-time.After(EventTimeout):
+		t.Fatal("Next blocked after Stop")
+	}
+}
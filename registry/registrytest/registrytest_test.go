@@ -0,0 +1,14 @@
+package registrytest
+
+import (
+	"github.com/asim/go-micro/v3/registry"
+	"testing"
+)
+
+// memory registry作为参照实现
+func TestMemoryRegistry(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+	Run(t, func() registry.Registry {
+		return r
+	})
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/DMwangnima/nacos-plugin/internal/snapfile"
+	"github.com/asim/go-micro/v3/registry"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+const (
+	// 从快照返回的服务会在Metadata中带上以下字段
+	SnapshotMetaKey     = "nacos.snapshot"
+	SnapshotTimeMetaKey = "nacos.snapshot.timestamp"
+)
+
+var errSnapshotExpired = errors.New("nacos registry snapshot expired")
+
+type snapshotFile struct {
+	// 内容写入的时间，之后确认内容未变化的时间见snapfile.VerifiedAt
+	Timestamp time.Time           `json:"timestamp"`
+	Services  []*registry.Service `json:"services"`
+}
+
+// snapshot 将每个服务最近一次获取的结果保存在磁盘上，一个服务一个文件
+type snapshot struct {
+	dir    string
+	maxAge time.Duration
+	mu     sync.Mutex
+	// 最近一次写入的内容，内容无变化时不重复写盘
+	last map[string]string
+}
+
+func newSnapshot(dir string, maxAge time.Duration) *snapshot {
+	return &snapshot{
+		dir:    dir,
+		maxAge: maxAge,
+		last:   make(map[string]string),
+	}
+}
+
+func (s *snapshot) save(service string, services []*registry.Service) error {
+	// 只以服务内容判断是否变化，时间戳不参与比较
+	content, err := json.Marshal(services)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last[service] == string(content) {
+		return snapfile.Touch(s.dir, service)
+	}
+	data, err := json.Marshal(snapshotFile{
+		Timestamp: time.Now(),
+		Services:  services,
+	})
+	if err != nil {
+		return err
+	}
+	if err := snapfile.Save(s.dir, service, data); err != nil {
+		return err
+	}
+	s.last[service] = string(content)
+	return nil
+}
+
+func (s *snapshot) load(service string) ([]*registry.Service, error) {
+	data, err := ioutil.ReadFile(snapfile.Path(s.dir, service))
+	if err != nil {
+		return nil, err
+	}
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	verified := snapfile.VerifiedAt(s.dir, service, file.Timestamp)
+	if s.maxAge > 0 && time.Since(verified) > s.maxAge {
+		return nil, errSnapshotExpired
+	}
+	for _, srv := range file.Services {
+		md := make(map[string]string, len(srv.Metadata)+2)
+		for k, v := range srv.Metadata {
+			md[k] = v
+		}
+		md[SnapshotMetaKey] = "true"
+		md[SnapshotTimeMetaKey] = verified.Format(time.RFC3339)
+		srv.Metadata = md
+	}
+	return file.Services, nil
+}
@@ -0,0 +1,128 @@
+package registry
+
+import (
+	"encoding/json"
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/fault"
+	"github.com/DMwangnima/nacos-plugin/internal/snapfile"
+	"github.com/DMwangnima/nacos-plugin/mock"
+	"github.com/asim/go-micro/v3/registry"
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSnapshotRegistry(naming naming_client.INamingClient, snapOpts ...nacos.SnapshotOption) registry.Registry {
+	return NewRegistry(
+		nacos.Client(nacos.NamespaceId("snapshot")),
+		nacos.Instance(nacos.Weight(10), nacos.Enable(true), nacos.Healthy(true)),
+		nacos.NamingClient(naming),
+		nacos.Snapshot(snapOpts...),
+	)
+}
+
+// ageSnapshot 将service快照的写入时间与确认时间提前d
+func ageSnapshot(t *testing.T, dir, service string, d time.Duration) {
+	t.Helper()
+	path := snapfile.Path(dir, service)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Timestamp = file.Timestamp.Add(-d)
+	if data, err = json.Marshal(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-d)
+	if err := os.Chtimes(strings.TrimSuffix(path, ".json")+".verified", past, past); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	naming := mock.NewNamingClient()
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "snapshot", Ip: "10.0.0.1", Port: 80})
+	if _, err := newSnapshotRegistry(naming, nacos.SnapshotDir(dir)).GetService("snapshot"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟重启后nacos不可用
+	down := fault.NewNamingClient(naming, fault.ErrorRate(1), fault.Methods("GetService"))
+	services, err := newSnapshotRegistry(down, nacos.SnapshotDir(dir)).GetService("snapshot")
+	if err != nil {
+		t.Fatalf("expected services from snapshot, err: %v", err)
+	}
+	if len(services) != 1 || len(services[0].Nodes) != 1 || services[0].Nodes[0].Address != "10.0.0.1:80" {
+		t.Fatalf("unexpected services %v", services)
+	}
+	if services[0].Metadata[SnapshotMetaKey] != "true" || services[0].Metadata[SnapshotTimeMetaKey] == "" {
+		t.Fatalf("missing staleness metadata: %v", services[0].Metadata)
+	}
+
+	if _, err := newSnapshotRegistry(down, nacos.SnapshotDir(dir)).GetService("unknown"); err != fault.ErrInjected {
+		t.Fatalf("expected nacos error without snapshot, got %v", err)
+	}
+}
+
+func TestSnapshotMaxAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	naming := mock.NewNamingClient()
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "snapshot", Ip: "10.0.0.1", Port: 80})
+	if _, err := newSnapshotRegistry(naming, nacos.SnapshotDir(dir)).GetService("snapshot"); err != nil {
+		t.Fatal(err)
+	}
+	ageSnapshot(t, dir, "snapshot", time.Hour)
+
+	down := fault.NewNamingClient(naming, fault.ErrorRate(1), fault.Methods("GetService"))
+	reg := newSnapshotRegistry(down, nacos.SnapshotDir(dir), nacos.SnapshotMaxAge(time.Minute))
+	if _, err := reg.GetService("snapshot"); err != fault.ErrInjected {
+		t.Fatalf("expected expired snapshot to be ignored, got %v", err)
+	}
+}
+
+func TestSnapshotMaxAgeSinceVerified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	naming := mock.NewNamingClient()
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "snapshot", Ip: "10.0.0.1", Port: 80})
+	reg := newSnapshotRegistry(naming, nacos.SnapshotDir(dir))
+	if _, err := reg.GetService("snapshot"); err != nil {
+		t.Fatal(err)
+	}
+	ageSnapshot(t, dir, "snapshot", time.Hour)
+	// 内容没有变化，但再次获取成功后快照重新计算有效期
+	if _, err := reg.GetService("snapshot"); err != nil {
+		t.Fatal(err)
+	}
+
+	down := fault.NewNamingClient(naming, fault.ErrorRate(1), fault.Methods("GetService"))
+	if _, err := newSnapshotRegistry(down, nacos.SnapshotDir(dir), nacos.SnapshotMaxAge(time.Minute)).GetService("snapshot"); err != nil {
+		t.Fatalf("expected snapshot verified recently, err: %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package registry
 
 import (
 	"errors"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/metrics"
 	"github.com/asim/go-micro/v3/logger"
 	"github.com/asim/go-micro/v3/registry"
 	"github.com/nacos-group/nacos-sdk-go/model"
@@ -21,8 +23,8 @@ type nacosWatcher struct {
 	retries int
 	options *registry.WatchOptions
 	mu      sync.RWMutex
-	// key为服务名，value为该service的node set
-	srvNodeMap map[string]map[string]struct{}
+	// key为服务名，value为该service的节点，key为节点地址，value为节点id
+	srvNodeMap map[string]map[string]string
 
 	exit chan bool
 	next chan *registry.Result
@@ -37,7 +39,7 @@ func newNacosWatcher(reg *nacosRegistry, opts ...registry.WatchOption) (registry
 		reg:        reg,
 		retries:    RETRIES,
 		options:    watchOptions,
-		srvNodeMap: make(map[string]map[string]struct{}),
+		srvNodeMap: make(map[string]map[string]string),
 		exit:       make(chan bool),
 		next:       make(chan *registry.Result, BUF_NUM),
 	}
@@ -46,11 +48,7 @@ func newNacosWatcher(reg *nacosRegistry, opts ...registry.WatchOption) (registry
 }
 
 func (w *nacosWatcher) subscribeServices() {
-	retry := func(service string, function func(*vo.SubscribeParam) error) {
-		param := &vo.SubscribeParam{
-			ServiceName:       service,
-			SubscribeCallback: w.watcherCallback,
-		}
+	retry := func(param *vo.SubscribeParam, function func(*vo.SubscribeParam) error) {
 		var err error
 		var i int
 		for i = 0; i < w.retries; i++ {
@@ -61,46 +59,88 @@ func (w *nacosWatcher) subscribeServices() {
 			break
 		}
 		if i >= w.retries {
-			logger.Logf(logger.ErrorLevel, "nacos unsubscribe service %s %d times failed", service, w.retries)
+			logger.Logf(logger.ErrorLevel, "nacos subscribe/unsubscribe service %s %d times failed, err: %v", param.ServiceName, w.retries, err)
 		}
 	}
 
-	services := []string{}
+	// 保存每个服务的订阅参数，退订时需使用同一个参数，sdk以回调函数的地址区分订阅者
+	params := []*vo.SubscribeParam{}
 	// 退出时取消订阅
 	defer func() {
-		for _, service := range services {
-			retry(service, w.reg.naming.Unsubscribe)
+		for _, param := range params {
+			retry(param, w.reg.naming.Unsubscribe)
 		}
 	}()
 
+	subscribe := func(service string) {
+		w.mu.Lock()
+		if _, ok := w.srvNodeMap[service]; ok {
+			w.mu.Unlock()
+			return
+		}
+		w.srvNodeMap[service] = make(map[string]string)
+		w.mu.Unlock()
+		param := &vo.SubscribeParam{
+			ServiceName: service,
+			SubscribeCallback: func(services []model.SubscribeService, err error) {
+				w.watcherCallback(service, services, err)
+			},
+		}
+		params = append(params, param)
+		go retry(param, w.reg.naming.Subscribe)
+	}
+
+	// 指定了服务时只订阅该服务，否则订阅所有查询过的服务
+	if w.options.Service != "" {
+		subscribe(divideNamespace(w.options.Service))
+		<-w.exit
+		return
+	}
+
 	for {
 		select {
 		case service := <-w.reg.serviceChan:
-			services = append(services, service)
-			w.mu.Lock()
-			w.srvNodeMap[service] = make(map[string]struct{})
-			w.mu.Unlock()
-			go retry(service, w.reg.naming.Subscribe)
+			subscribe(service)
 		case <-w.exit:
 			return
 		}
 	}
 }
 
-func (w *nacosWatcher) watcherCallback(services []model.SubscribeService, err error) {
-	if err != nil || len(services) == 0 {
-		// 需要使用日志记录
-		return
-	}
-	key := services[0].ServiceName
-	oldNodeSet := make(map[string]struct{})
-	// 创建一个副本
+func (w *nacosWatcher) watcherCallback(key string, services []model.SubscribeService, err error) {
 	w.mu.RLock()
-	for k := range w.srvNodeMap[key] {
-		oldNodeSet[k] = struct{}{}
+	oldNodeSet := make(map[string]string)
+	// 创建一个副本
+	for k, v := range w.srvNodeMap[key] {
+		oldNodeSet[k] = v
 	}
 	w.mu.RUnlock()
 
+	// sdk在服务下没有实例时回调空列表和error
+	if err != nil || len(services) == 0 {
+		if len(oldNodeSet) == 0 {
+			return
+		}
+		oldService := &registry.Service{
+			Name:  key,
+			Nodes: make([]*registry.Node, 0, len(oldNodeSet)),
+		}
+		for address, id := range oldNodeSet {
+			oldService.Nodes = append(oldService.Nodes, &registry.Node{Id: id, Address: address})
+		}
+		w.mu.Lock()
+		w.srvNodeMap[key] = make(map[string]string)
+		w.mu.Unlock()
+		w.reg.metrics.Counter(metrics.RegistryWatchEvents, metrics.Labels{"service": key, "action": "delete"}, 1)
+		w.reg.hooks.Event(hook.RegistryWatchEvent, map[string]string{"service": key, "action": "delete"})
+		w.reg.metrics.Gauge(metrics.RegistryNodes, metrics.Labels{"service": key}, 0)
+		w.send(&registry.Result{
+			Action:  "delete",
+			Service: oldService,
+		})
+		return
+	}
+
 	if len(services) == len(oldNodeSet) {
 		var flag bool
 		for _, node := range services {
@@ -129,17 +169,29 @@ func (w *nacosWatcher) watcherCallback(services []model.SubscribeService, err er
 		}
 	}
 
-	newNodeSet := make(map[string]struct{})
+	newNodeSet := make(map[string]string)
 	for _, n := range newService.Nodes {
-		newNodeSet[n.Address] = struct{}{}
+		newNodeSet[n.Address] = n.Id
 	}
 	w.mu.Lock()
 	w.srvNodeMap[key] = newNodeSet
 	w.mu.Unlock()
 
-	w.next <- &registry.Result{
+	w.reg.metrics.Counter(metrics.RegistryWatchEvents, metrics.Labels{"service": key, "action": "update"}, 1)
+	w.reg.hooks.Event(hook.RegistryWatchEvent, map[string]string{"service": key, "action": "update"})
+	w.reg.metrics.Gauge(metrics.RegistryNodes, metrics.Labels{"service": key}, float64(len(newService.Nodes)))
+	w.send(&registry.Result{
 		Action:  "update",
 		Service: newService,
+	})
+}
+
+// watcher停止后不再阻塞sdk的回调
+func (w *nacosWatcher) send(result *registry.Result) {
+	select {
+	case <-w.exit:
+	case w.next <- result:
+		w.reg.metrics.Gauge(metrics.RegistryWatchQueue, nil, float64(len(w.next)))
 	}
 }
 
@@ -151,6 +203,7 @@ func (w *nacosWatcher) Next() (*registry.Result, error) {
 		if !ok {
 			return nil, errors.New("nacos subscribe has something wrong")
 		}
+		w.reg.metrics.Gauge(metrics.RegistryWatchQueue, nil, float64(len(w.next)))
 		return result, nil
 	}
 }
@@ -3,9 +3,14 @@ package registry
 import (
 	"fmt"
 	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/fault"
+	"github.com/DMwangnima/nacos-plugin/mock"
 	"github.com/asim/go-micro/v3/registry"
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/vo"
 	"sync"
 	"testing"
+	"time"
 )
 
 func newRegistry() registry.Registry {
@@ -64,3 +69,104 @@ func TestNacosWatcher_Next(t *testing.T) {
 		}
 	}
 }
+
+func newFaultRegistry(naming naming_client.INamingClient) registry.Registry {
+	return NewRegistry(
+		nacos.Client(nacos.NamespaceId("fault")),
+		nacos.Instance(
+			nacos.Weight(10),
+			nacos.Enable(true),
+			nacos.Healthy(true),
+			nacos.Ephemeral(true),
+		),
+		nacos.NamingClient(naming),
+	)
+}
+
+func nextResult(t *testing.T, w registry.Watcher) *registry.Result {
+	res := make(chan *registry.Result, 1)
+	go func() {
+		r, err := w.Next()
+		if err == nil {
+			res <- r
+		}
+	}()
+	select {
+	case r := <-res:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+func TestWatchSubscribeRetry(t *testing.T) {
+	naming := mock.NewNamingClient()
+	// 前两次订阅失败，第三次重试成功
+	reg := newFaultRegistry(fault.NewNamingClient(naming, fault.ErrorRate(1), fault.MaxFaults(RETRIES-1), fault.Methods("Subscribe")))
+	w, err := reg.Watch(registry.WatchService("chaos-retry"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "chaos-retry", Ip: "10.0.0.1", Port: 80})
+	if res := nextResult(t, w); res.Action != "update" || len(res.Service.Nodes) != 1 {
+		t.Fatalf("unexpected event %s with %d nodes", res.Action, len(res.Service.Nodes))
+	}
+}
+
+// subscribedNaming 每次Subscribe返回后通知subscribed
+type subscribedNaming struct {
+	naming_client.INamingClient
+	subscribed chan string
+}
+
+func (s *subscribedNaming) Subscribe(param *vo.SubscribeParam) error {
+	err := s.INamingClient.Subscribe(param)
+	s.subscribed <- param.ServiceName
+	return err
+}
+
+func TestWatchDroppedCallback(t *testing.T) {
+	naming := mock.NewNamingClient()
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "chaos-drop", Ip: "10.0.0.1", Port: 80})
+	// 订阅时的首次回调被丢弃，之后的推送携带完整的节点列表
+	client := &subscribedNaming{
+		INamingClient: fault.NewNamingClient(naming, fault.DropRate(1), fault.MaxFaults(1)),
+		subscribed:    make(chan string, 1),
+	}
+	reg := newFaultRegistry(client)
+	w, err := reg.Watch(registry.WatchService("chaos-drop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	// 订阅完成后再注册，保证新节点通过推送送达
+	select {
+	case <-client.subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not subscribe")
+	}
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "chaos-drop", Ip: "10.0.0.2", Port: 80})
+	if res := nextResult(t, w); len(res.Service.Nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(res.Service.Nodes))
+	}
+}
+
+func TestRegisterFault(t *testing.T) {
+	naming := mock.NewNamingClient()
+	reg := newFaultRegistry(fault.NewNamingClient(naming, fault.ErrorRate(1), fault.MaxFaults(1), fault.Methods("RegisterInstance")))
+	s := &registry.Service{
+		Name:  "chaos-register",
+		Nodes: []*registry.Node{{Id: "1", Address: "10.0.0.1:80"}},
+	}
+	if err := reg.Register(s); err != fault.ErrInjected {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+	if err := reg.Register(s); err != nil {
+		t.Fatalf("register after fault failed: %v", err)
+	}
+	if services, err := reg.GetService("chaos-register"); err != nil || len(services[0].Nodes) != 1 {
+		t.Fatalf("expected registered node, err: %v", err)
+	}
+}
@@ -1,7 +1,11 @@
 package selector
 
 import (
+	"context"
 	"errors"
+	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/metrics"
 	microErr "github.com/asim/go-micro/v3/errors"
 	"github.com/asim/go-micro/v3/registry"
 	"github.com/asim/go-micro/v3/registry/cache"
@@ -17,7 +21,11 @@ const (
 type nacosSelector struct {
 	options selector.Options
 	cache   cache.Cache
-	exit    chan struct{}
+	metrics metrics.Metrics
+	hooks   hook.Hooks
+	// 记录每次调用的hook与指标
+	observer hook.Observer
+	exit     chan struct{}
 
 	mu sync.RWMutex
 	// 各服务对应的next func
@@ -39,8 +47,8 @@ func (n *nacosSelector) Options() selector.Options {
 	return n.options
 }
 
-// 定期请求缓存
-func (n *nacosSelector) watch(service string, update chan []*registry.Service) {
+// 定期请求缓存，services为Select时首次请求的结果
+func (n *nacosSelector) watch(service string, update chan []*registry.Service, services []*registry.Service) {
 	updateFunc := func() {
 		services, err := n.cache.GetService(service)
 		if err != nil {
@@ -48,8 +56,7 @@ func (n *nacosSelector) watch(service string, update chan []*registry.Service) {
 		}
 		update <- services
 	}
-	// 第一次调用时，直接请求一次缓存
-	updateFunc()
+	update <- services
 	ticker := time.NewTicker(REFRESH_INTERVAL)
 	defer ticker.Stop()
 
@@ -104,13 +111,15 @@ func (n *nacosSelector) changeNext(service string, update chan []*registry.Servi
 	}
 }
 
-func (n *nacosSelector) Select(service string, opts ...selector.SelectOption) (selector.Next, error) {
+func (n *nacosSelector) Select(service string, opts ...selector.SelectOption) (nextFunc selector.Next, err error) {
 	sOpts := selector.SelectOptions{
 		Strategy: n.options.Strategy,
 	}
 	for _, opt := range opts {
 		opt(&sOpts)
 	}
+	_, done := n.observer.Observe(sOpts.Context, hook.SelectorSelect, "select", map[string]string{"service": service})
+	defer func() { done(err) }()
 
 	n.mu.RLock()
 	if funct, ok := n.userNextFuncs[service]; ok {
@@ -119,13 +128,21 @@ func (n *nacosSelector) Select(service string, opts ...selector.SelectOption) (s
 	}
 	n.mu.RUnlock()
 
+	// 先同步请求一次缓存，失败时直接返回，避免Select一直阻塞
+	services, err := n.cache.GetService(service)
+	if err == registry.ErrNotFound {
+		return nil, selector.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
 	n.mu.Lock()
 	n.markMap[service] = make(chan string)
 	n.markFinMap[service] = make(chan struct{})
 	n.mu.Unlock()
 
 	update := make(chan []*registry.Service)
-	go n.watch(service, update)
+	go n.watch(service, update, services)
 	onceFinish := make(chan struct{})
 	go n.changeNext(service, update, onceFinish)
 
@@ -158,6 +175,8 @@ func (n *nacosSelector) Mark(service string, node *registry.Node, err error) {
 	default:
 		return
 	}
+	_, done := n.observer.Observe(context.Background(), hook.SelectorMark, "mark", map[string]string{"service": service, "node": node.Address})
+	defer done(err)
 	n.mu.RLock()
 	mark := n.markMap[service]
 	markFin := n.markFinMap[service]
@@ -207,6 +226,16 @@ func NewSelector(opts ...selector.Option) selector.Selector {
 		userNextFuncs: make(map[string]selector.Next),
 		markFinMap:    make(map[string]chan struct{}),
 	}
+	s.metrics = metrics.Noop()
+	if options.Context != nil {
+		if m, ok := options.Context.Value(nacos.MetricsKey{}).(metrics.Metrics); ok {
+			s.metrics = m
+		}
+		if hooks, ok := options.Context.Value(nacos.HookKey{}).(hook.Hooks); ok {
+			s.hooks = hooks
+		}
+	}
+	s.observer = hook.Observer{Hooks: s.hooks, Metrics: s.metrics, Requests: metrics.SelectorRequests, Latency: metrics.SelectorLatency}
 	s.cache = s.newCache()
 	return s
 }
@@ -3,9 +3,14 @@ package selector
 import (
 	"fmt"
 	"github.com/DMwangnima/nacos-plugin"
+	"github.com/DMwangnima/nacos-plugin/fault"
+	"github.com/DMwangnima/nacos-plugin/hook"
+	"github.com/DMwangnima/nacos-plugin/mock"
 	nacosReg "github.com/DMwangnima/nacos-plugin/registry"
+	microErr "github.com/asim/go-micro/v3/errors"
 	"github.com/asim/go-micro/v3/registry"
 	"github.com/asim/go-micro/v3/selector"
+	"github.com/nacos-group/nacos-sdk-go/vo"
 	"testing"
 	"time"
 )
@@ -127,3 +132,83 @@ func TestMark(t *testing.T) {
 	printErr(err)
 	fmt.Println(node.Address)
 }
+
+func newFaultRegistry(naming *mock.NamingClient, opts ...fault.Option) registry.Registry {
+	return nacosReg.NewRegistry(
+		nacos.Client(nacos.NamespaceId("fault")),
+		nacos.Instance(
+			nacos.Weight(10),
+			nacos.Enable(true),
+			nacos.Healthy(true),
+			nacos.Ephemeral(true),
+		),
+		nacos.NamingClient(fault.NewNamingClient(naming, opts...)),
+	)
+}
+
+func TestSelectLatency(t *testing.T) {
+	naming := mock.NewNamingClient()
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "chaos-latency", Ip: "10.0.0.1", Port: 80})
+	s := NewSelector(selector.Registry(newFaultRegistry(naming, fault.Latency(20*time.Millisecond, 20*time.Millisecond))))
+	defer s.Close()
+	next, err := s.Select("chaos-latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := next()
+	if err != nil || node.Address != "10.0.0.1:80" {
+		t.Fatalf("unexpected node %v, err: %v", node, err)
+	}
+}
+
+func TestSelectFault(t *testing.T) {
+	naming := mock.NewNamingClient()
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "chaos-fault", Ip: "10.0.0.1", Port: 80})
+	s := NewSelector(selector.Registry(newFaultRegistry(naming, fault.ErrorRate(1), fault.MaxFaults(1), fault.Methods("GetService"))))
+	defer s.Close()
+	// 首次请求失败时Select应返回错误而不是阻塞
+	if _, err := s.Select("chaos-fault"); err == nil {
+		t.Fatal("expected error from first select")
+	}
+	next, err := s.Select("chaos-fault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node, err := next(); err != nil || node.Address != "10.0.0.1:80" {
+		t.Fatalf("unexpected node %v, err: %v", node, err)
+	}
+}
+
+func TestSelectNotFound(t *testing.T) {
+	s := NewSelector(selector.Registry(newFaultRegistry(mock.NewNamingClient())))
+	defer s.Close()
+	if _, err := s.Select("chaos-missing"); err != selector.ErrNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestMarkHook(t *testing.T) {
+	naming := mock.NewNamingClient()
+	naming.RegisterInstance(vo.RegisterInstanceParam{ServiceName: "chaos-mark", Ip: "10.0.0.1", Port: 80})
+	var marked error
+	hooks := hook.Hooks{hook.Funcs{AfterFunc: func(op *hook.Operation) {
+		if op.Name == hook.SelectorMark {
+			marked = op.Err
+		}
+	}}}
+	s := NewSelector(selector.Registry(newFaultRegistry(naming)), nacos.SelectorHooks(hooks...))
+	defer s.Close()
+	next, err := s.Select("chaos-mark")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errUnavailable := microErr.New("chaos", "unavailable", 14)
+	s.Mark("chaos-mark", node, errUnavailable)
+	if marked != errUnavailable {
+		t.Fatalf("expected mark error reported to hook, got %v", marked)
+	}
+}
@@ -0,0 +1,139 @@
+// signature 配置内容的签名与校验，签名可以放在内容末尾的一行(trailer)或单独的伴随DataId中
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// TrailerPrefix 内容最后一行以该前缀开头时视为签名，签名不包括该行及其之前的换行
+const TrailerPrefix = "#nacos-signature:"
+
+var (
+	ErrUnsigned  = errors.New("signature: content is not signed")
+	ErrSignature = errors.New("signature: invalid signature")
+)
+
+type Signer interface {
+	Sign(data []byte) ([]byte, error)
+}
+
+type Verifier interface {
+	Verify(data, sig []byte) error
+}
+
+// HMAC HMAC-SHA256，同时用于签名与校验
+type HMAC struct {
+	key []byte
+}
+
+func NewHMAC(key []byte) HMAC {
+	return HMAC{key: key}
+}
+
+func (h HMAC) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func (h HMAC) Verify(data, sig []byte) error {
+	expected, _ := h.Sign(data)
+	if !hmac.Equal(expected, sig) {
+		return ErrSignature
+	}
+	return nil
+}
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+func NewEd25519Signer(key ed25519.PrivateKey) Signer {
+	return ed25519Signer{key: key}
+}
+
+func (e ed25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(e.key, data), nil
+}
+
+type ed25519Verifier struct {
+	keys []ed25519.PublicKey
+}
+
+// NewEd25519Verifier 签名由任意一个受信任的公钥校验通过即可，便于轮换密钥
+func NewEd25519Verifier(keys ...ed25519.PublicKey) Verifier {
+	return ed25519Verifier{keys: keys}
+}
+
+func (e ed25519Verifier) Verify(data, sig []byte) error {
+	for _, key := range e.keys {
+		if ed25519.Verify(key, data, sig) {
+			return nil
+		}
+	}
+	return ErrSignature
+}
+
+type anyVerifier []Verifier
+
+// Any 任意一个Verifier校验通过即可
+func Any(verifiers ...Verifier) Verifier {
+	return anyVerifier(verifiers)
+}
+
+func (a anyVerifier) Verify(data, sig []byte) error {
+	for _, v := range a {
+		if v.Verify(data, sig) == nil {
+			return nil
+		}
+	}
+	return ErrSignature
+}
+
+// Detached 返回内容的签名，用于写入伴随DataId
+func Detached(s Signer, content string) (string, error) {
+	sig, err := s.Sign([]byte(content))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Sign 返回在末尾加上签名行的内容，内容中已有的签名行会被替换
+func Sign(s Signer, content string) (string, error) {
+	body, _, _ := Split(content)
+	sig, err := Detached(s, body)
+	if err != nil {
+		return "", err
+	}
+	return body + "\n" + TrailerPrefix + " " + sig + "\n", nil
+}
+
+// Split 拆分内容与末尾的签名行，没有签名行时ok为false
+func Split(content string) (body, sig string, ok bool) {
+	trimmed := strings.TrimRight(content, "\r\n")
+	idx := strings.LastIndexByte(trimmed, '\n')
+	line := trimmed[idx+1:]
+	if !strings.HasPrefix(line, TrailerPrefix) {
+		return content, "", false
+	}
+	body = ""
+	if idx >= 0 {
+		body = strings.TrimSuffix(trimmed[:idx], "\r")
+	}
+	return body, strings.TrimSpace(strings.TrimPrefix(line, TrailerPrefix)), true
+}
+
+// Verify 校验base64编码的签名
+func Verify(v Verifier, content, sig string) error {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sig))
+	if err != nil {
+		return ErrSignature
+	}
+	return v.Verify([]byte(content), raw)
+}
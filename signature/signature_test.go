@@ -0,0 +1,61 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSignTrailer(t *testing.T) {
+	h := NewHMAC([]byte("key"))
+	for _, content := range []string{"a: 1", "a: 1\n", "{\"a\":1}\r\n", ""} {
+		signed, err := Sign(h, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 重复签名替换已有的签名行
+		if again, _ := Sign(h, signed); again != signed {
+			t.Fatalf("unexpected resigned content %q", again)
+		}
+		body, sig, ok := Split(signed)
+		if !ok || body != content {
+			t.Fatalf("unexpected body %q of %q", body, signed)
+		}
+		if err := Verify(h, body, sig); err != nil {
+			t.Fatal(err)
+		}
+		if err := Verify(h, body+" ", sig); err != ErrSignature {
+			t.Fatalf("expected invalid signature, got %v", err)
+		}
+	}
+	if _, _, ok := Split("a: 1\n# comment\n"); ok {
+		t.Fatal("unexpected signature")
+	}
+}
+
+func TestEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := Detached(NewEd25519Signer(priv), "a: 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(NewEd25519Verifier(oldPub, pub), "a: 1", sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(NewEd25519Verifier(oldPub), "a: 1", sig); err != ErrSignature {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+	if err := Verify(Any(NewHMAC([]byte("key")), NewEd25519Verifier(pub)), "a: 1", sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(NewEd25519Verifier(pub), "a: 1", "!"); err != ErrSignature {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+}